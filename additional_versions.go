@@ -0,0 +1,145 @@
+package pnpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// installAdditionalVersions installs every pnpm version listed in
+// BP_PNPM_ADDITIONAL_VERSIONS next to the default one. Each version goes into
+// a layer of its own named after its major version, e.g. pnpm-8, and is
+// exposed as a command of the same name so that it never shadows the default
+// pnpm on the PATH.
+func installAdditionalVersions(
+	context packit.BuildContext,
+	dependencyManager DependencyManager,
+	sbomGenerator SBOMGenerator,
+	clock chronos.Clock,
+	logger scribe.Emitter,
+	dependencyID string,
+	distribution string,
+	launch, build bool,
+) ([]packit.Layer, error) {
+	versions, ok := os.LookupEnv("BP_PNPM_ADDITIONAL_VERSIONS")
+	if !ok || strings.TrimSpace(versions) == "" {
+		return nil, nil
+	}
+
+	var layers []packit.Layer
+	names := map[string]string{}
+	for _, version := range strings.Split(versions, ",") {
+		version = strings.TrimSpace(version)
+		if version == "" {
+			continue
+		}
+
+		constraint, err := translateVersionRange(version)
+		if err != nil {
+			return nil, err
+		}
+
+		dependency, err := dependencyManager.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), dependencyID, constraint, context.Stack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve additional pnpm version %q: %w", version, err)
+		}
+
+		semverVersion, err := semver.NewVersion(dependency.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pnpm version %q: %w", dependency.Version, err)
+		}
+
+		name := fmt.Sprintf("%s-%d", PnpmLayerName, semverVersion.Major())
+		if previous, ok := names[name]; ok {
+			return nil, fmt.Errorf("BP_PNPM_ADDITIONAL_VERSIONS lists %q and %q, which both resolve to %s", previous, version, name)
+		}
+		names[name] = version
+
+		logger.Process("Installing additional pnpm version %s as %s", dependency.Version, name)
+
+		layer, err := context.Layers.Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		cachedSHA, ok := layer.Metadata[DependencyCacheKey].(string)
+		if ok && postal.Checksum(dependency.Checksum).MatchString(cachedSHA) {
+			logger.Subprocess("Reusing cached layer %s", layer.Path)
+			logger.Break()
+
+			layer.Launch, layer.Build, layer.Cache = launch, build, build
+			layers = append(layers, layer)
+
+			continue
+		}
+
+		layer, err = layer.Reset()
+		if err != nil {
+			return nil, err
+		}
+
+		layer.Launch, layer.Build, layer.Cache = launch, build, build
+
+		duration, err := clock.Measure(func() error {
+			err := dependencyManager.Deliver(dependency, context.CNBPath, layer.Path, context.Platform.Path)
+			if err != nil {
+				return err
+			}
+
+			return linkVersionedCommand(layer.Path, name, distribution)
+		})
+		if err != nil {
+			return nil, err
+		}
+		logger.Action("Completed in %s", duration.Round(time.Millisecond))
+		logger.Break()
+
+		layer, err = attachSBOM(layer, name, dependency, context.BuildpackInfo.SBOMFormats, sbomGenerator, clock, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		layer.Metadata = map[string]interface{}{
+			DependencyCacheKey: dependency.Checksum,
+		}
+
+		layer.SharedEnv.Prepend("PATH", filepath.Join(layer.Path, VersionedCommandDir), string(os.PathListSeparator))
+
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// linkVersionedCommand links the pnpm executable delivered into the layer as
+// <name> in a directory of its own. Only that directory goes on the PATH, so
+// that the other scripts the delivered distribution ships, such as the
+// bin/pnpm.cjs and bin/pnpx.cjs of the npm tarball, never shadow those of the
+// default pnpm.
+func linkVersionedCommand(layerPath, name, distribution string) error {
+	target := filepath.Join("..", "pnpm")
+	if distribution == DistributionJS {
+		target = filepath.Join("..", "bin", "pnpm.cjs")
+	}
+
+	commandDir := filepath.Join(layerPath, VersionedCommandDir)
+	err := os.MkdirAll(commandDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s directory: %w", VersionedCommandDir, err)
+	}
+
+	err = os.Symlink(target, filepath.Join(commandDir, name))
+	if err != nil {
+		return fmt.Errorf("failed to link %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -1,18 +1,29 @@
 package pnpm
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/draft"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/postal"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 //go:generate faux --interface DependencyManager --output fakes/dependency_manager.go
@@ -22,14 +33,57 @@ type DependencyManager interface {
 	GenerateBillOfMaterials(dependencies ...postal.Dependency) []packit.BOMEntry
 }
 
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ, provider, platformDir string) ([]servicebindings.Binding, error)
+}
+
+//go:generate faux --interface Executable --output fakes/executable.go
+type Executable interface {
+	Execute(pexec.Execution) error
+}
+
 //go:generate faux --interface SBOMGenerator --output fakes/sbom_generator.go
 type SBOMGenerator interface {
 	GenerateFromDependency(dependency postal.Dependency, dir string) (sbom.SBOM, error)
 }
 
+// muslDistros lists the target distributions known to ship musl instead of
+// glibc, on which only the statically linked executable runs. Any other
+// distribution is assumed to ship glibc.
+var muslDistros = []string{
+	"adelie",
+	"alpine",
+	"chimera",
+	"postmarketos",
+}
+
+// muslLoaders matches the dynamic loader of musl, which the build image holds
+// when it is based on a distribution that ships musl.
+var muslLoaders = "/lib/ld-musl-*.so.1"
+
+// versionSourcePriorities lists the version-sources of pnpm buildpack plan
+// entries, from the highest to the lowest priority. Entries without a
+// version-source rank below all of them.
+var versionSourcePriorities = []interface{}{
+	"BP_PNPM_VERSION",
+	"package.json",
+	"volta",
+	"mise.toml",
+	".tool-versions",
+	"devEngines",
+	"engines",
+	"pnpm-lock.yaml",
+	"buildplan",
+}
+
 func Build(
 	dependencyManager DependencyManager,
 	sbomGenerator SBOMGenerator,
+	bindingResolver BindingResolver,
+	nodeExecutable Executable,
+	corepackExecutable Executable,
+	pnpmExecutable Executable,
 	clock chronos.Clock,
 	logger scribe.Emitter,
 ) packit.BuildFunc {
@@ -41,26 +95,249 @@ func Build(
 			return packit.BuildResult{}, err
 		}
 
+		installMode, err := checkInstallMode()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		distribution, err := checkDistribution(installMode)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		prefetch, err := checkPrefetchEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		buildSettings, launchSettings, err := configSettings()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		logger.Process("Resolving pnpm version")
+
+		buildpackTOMLPath := filepath.Join(context.CNBPath, "buildpack.toml")
+		dependencyID := PnpmDependency
+		if distribution == DistributionJS {
+			dependencyID = PnpmJSDependency
+		} else {
+			static, err := checkStaticExecutable(buildpackTOMLPath, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if static {
+				logger.Subprocess("Selecting the statically linked pnpm executable")
+				dependencyID = PnpmStaticDependency
+			}
+		}
+
 		planner := draft.NewPlanner()
-		entry, _ := planner.Resolve("pnpm", context.Plan.Entries, nil)
-		version, ok := entry.Metadata["version"].(string)
-		if !ok {
-			version = "default"
+		entry, sortedEntries := planner.Resolve(PnpmDependency, context.Plan.Entries, versionSourcePriorities)
+		logger.Candidates(sortedEntries)
+		logOverriddenSources(logger, entry, sortedEntries)
+
+		constraints, err := requiredConstraints(sortedEntries)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		dependency, err := resolveConstraints(dependencyManager, logger, buildpackTOMLPath, dependencyID, context.Stack, constraints)
+		if err != nil {
+			// onFail only applies when devEngines.packageManager itself rules out
+			// every version, not when another constraint conflicts with it.
+			onFail, _ := entry.Metadata["on-fail"].(string)
+			if !isDevEnginesSource(entry) || !devEnginesUnsatisfiable(dependencyManager, buildpackTOMLPath, dependencyID, context.Stack, constraints) {
+				onFail = ""
+			}
+
+			switch onFail {
+			case "error":
+				return packit.BuildResult{}, fmt.Errorf("no pnpm version satisfies %q required by devEngines.packageManager: %w", constraints[0].Constraint, err)
+			case "warn", "ignore":
+				// The sources below devEngines decide the version, as if the
+				// application did not declare it.
+				fallbackEntries := slices.DeleteFunc(slices.Clone(sortedEntries), isDevEnginesSource)
+				fallbackConstraints, err := requiredConstraints(fallbackEntries)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if onFail == "warn" {
+					logger.Process("WARNING: no pnpm version satisfies %q required by devEngines.packageManager, falling back to %s", constraints[0].Constraint, describeConstraints(fallbackConstraints))
+					logger.Break()
+				}
+
+				dependency, err = resolveConstraints(dependencyManager, logger, buildpackTOMLPath, dependencyID, context.Stack, fallbackConstraints)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if len(fallbackEntries) > 0 {
+					entry = fallbackEntries[0]
+				}
+			default:
+				return packit.BuildResult{}, err
+			}
+		} else {
+			err = checkDevEngines(sortedEntries, entry, dependency.Version, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
 		}
 
-		dependency, err := dependencyManager.Resolve(
-			filepath.Join(context.CNBPath, "buildpack.toml"),
-			entry.Name,
-			version,
-			context.Stack)
+		logger.SelectedDependency(entry, dependency, clock.Now())
+
+		node, err := resolveNode(context, clock, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		bom := dependencyManager.GenerateBillOfMaterials(dependency)
+		// The Node.js installed by this buildpack already satisfies the
+		// requirement derived from the node-compatibility table.
+		if node == nil {
+			err = checkNodeCompatibility(nodeExecutable, buildpackTOMLPath, dependency.Version)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		var (
+			toolDependency   *postal.Dependency
+			disableSwitching bool
+		)
+
+		requestedVersion := requestedPackageManagerVersion(sortedEntries)
+		if switchesVersions(installMode, dependency.Version, requestedVersion) {
+			seed, err := dependencyManager.Resolve(buildpackTOMLPath, dependencyID, requestedVersion, context.Stack)
+			switch {
+			case err == nil:
+				logger.Process("package.json requests pnpm %s while pnpm %s is installed", requestedVersion, dependency.Version)
+				logger.Subprocess("Pre-seeding pnpm %s so that switching to it works offline", requestedVersion)
+				logger.Break()
+
+				toolDependency = &seed
+			case installMode == InstallModeCorepack:
+				logger.Process("WARNING: package.json requests pnpm %s, which this buildpack does not provide, so corepack will attempt to download it", requestedVersion)
+				logger.Break()
+			default:
+				logger.Process("WARNING: package.json requests pnpm %s, which this buildpack does not provide", requestedVersion)
+				logger.Subprocess("Disabling manage-package-manager-versions so that pnpm %s does not attempt to download it", dependency.Version)
+				logger.Break()
+
+				disableSwitching = true
+			}
+		}
+
+		// The packageManager hash only applies when the pinned version is the
+		// one that ends up running.
+		integrity := requestedPackageManagerIntegrity(sortedEntries)
+		if integrity != "" && (dependency.Version == requestedVersion || toolDependency != nil) {
+			pinned := dependency
+			if toolDependency != nil {
+				pinned = *toolDependency
+			}
+
+			err = verifyPackageManagerIntegrity(pinned, integrity, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		dependencies := []postal.Dependency{dependency}
+		if toolDependency != nil {
+			dependencies = append(dependencies, *toolDependency)
+		}
+
+		bom := dependencyManager.GenerateBillOfMaterials(dependencies...)
 
 		launch, build := planner.MergeLayerTypes("pnpm", context.Plan.Entries)
 
+		additionalLayers, err := installAdditionalVersions(context, dependencyManager, sbomGenerator, clock, logger, dependencyID, distribution, launch, build)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// A pinned version that pnpm switches to is the one that writes to the
+		// store.
+		storePnpmVersion := dependency.Version
+		if toolDependency != nil {
+			storePnpmVersion = toolDependency.Version
+		}
+
+		storeLayer, err := installStore(context, storePnpmVersion, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		logConfigSettings(buildSettings, launchSettings, logger)
+
+		// Unless the settings say otherwise, pnpm must not attempt to download a
+		// version the buildpack does not provide, during the build or, when it is
+		// available there, at launch.
+		if disableSwitching {
+			settingsOfPhases := []map[string]string{buildSettings}
+			if launch {
+				settingsOfPhases = append(settingsOfPhases, launchSettings)
+			}
+
+			for _, settings := range settingsOfPhases {
+				if _, ok := settings["npm_config_manage_package_manager_versions"]; !ok {
+					settings["npm_config_manage_package_manager_versions"] = "false"
+				}
+			}
+		}
+
+		// Build settings only reach later buildpacks during the build, through a
+		// layer of their own, while launch settings are part of the pnpm layer.
+		configLayers, err := installConfig(context, bindingResolver, buildSettings, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		binPath := pnpmLayer.Path
+		if installMode == InstallModeCorepack || distribution == DistributionJS {
+			binPath = filepath.Join(pnpmLayer.Path, "bin")
+		}
+
+		pnpmEnv := map[string]string{}
+		if installMode == InstallModeCorepack {
+			pnpmEnv["COREPACK_HOME"] = filepath.Join(pnpmLayer.Path, "corepack")
+		}
+
+		if toolDependency != nil && installMode != InstallModeCorepack {
+			pnpmEnv["PNPM_HOME"] = filepath.Join(pnpmLayer.Path, "pnpm-home")
+		}
+
+		var nodeLayers []packit.Layer
+
+		// pnpm maintains the store with the environment that the pnpm layer, and
+		// the node layer when there is one, give to later buildpacks.
+		maintainStore := func() error {
+			paths := []string{binPath}
+			for _, nodeLayer := range nodeLayers {
+				paths = append(paths, nodeLayer.Path)
+			}
+
+			env := append(os.Environ(), fmt.Sprintf("PATH=%s", strings.Join(append(paths, os.Getenv("PATH")), string(os.PathListSeparator))))
+			for _, name := range slices.Sorted(maps.Keys(pnpmEnv)) {
+				env = append(env, fmt.Sprintf("%s=%s", name, pnpmEnv[name]))
+			}
+			for _, configLayer := range configLayers {
+				env = append(env, environmentOverrides(configLayer)...)
+			}
+			env = append(env, fmt.Sprintf("npm_config_store_dir=%s", storeLayer.Path))
+
+			storeLayer, err = pruneStore(pnpmExecutable, storeLayer, env, clock, logger)
+			if err != nil || !prefetch {
+				return err
+			}
+
+			storeLayer, err = prefetchStore(context, pnpmExecutable, storeLayer, env, clock, logger)
+			return err
+		}
+
 		var buildMetadata = packit.BuildMetadata{}
 		var launchMetadata = packit.LaunchMetadata{}
 		if build {
@@ -71,20 +348,63 @@ func Build(
 			launchMetadata = packit.LaunchMetadata{BOM: bom}
 		}
 
-		cachedSHA, ok := pnpmLayer.Metadata[DependencyCacheKey].(string)
-		if ok && postal.Checksum(dependency.Checksum).MatchString(cachedSHA) {
-			logger.Process("Reusing cached layer %s", pnpmLayer.Path)
-			logger.Break()
+		// Once pnpm is in place, it installs Node.js and maintains the store,
+		// after which the layers of the build are complete.
+		complete := func() (packit.BuildResult, error) {
+			nodeLayers, err = installNode(context, node, pnpmExecutable, binPath, sbomGenerator, clock, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
 
-			pnpmLayer.Launch, pnpmLayer.Build, pnpmLayer.Cache = launch, build, build
+			err = maintainStore()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layers := append([]packit.Layer{pnpmLayer}, additionalLayers...)
+			layers = append(append(append(layers, nodeLayers...), configLayers...), storeLayer)
+			err = scrubLaunchCredentials(layers, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
 
 			return packit.BuildResult{
-				Layers: []packit.Layer{pnpmLayer},
+				Layers: layers,
 				Build:  buildMetadata,
 				Launch: launchMetadata,
 			}, nil
 		}
 
+		cachedInstallMode, ok := pnpmLayer.Metadata[InstallModeKey].(string)
+		if !ok {
+			cachedInstallMode = InstallModeStandalone
+		}
+
+		var toolSHA string
+		if toolDependency != nil {
+			toolSHA = toolDependency.Checksum
+		}
+
+		cachedToolSHA, _ := pnpmLayer.Metadata[ToolDependencyCacheKey].(string)
+		manageVersions, ok := pnpmLayer.Metadata[ManagePackageManagerVersionsKey].(bool)
+		cachedDisableSwitching := ok && !manageVersions
+
+		cachedSHA, ok := pnpmLayer.Metadata[DependencyCacheKey].(string)
+		if ok && postal.Checksum(dependency.Checksum).MatchString(cachedSHA) && cachedInstallMode == installMode &&
+			cachedToolSHA == toolSHA && cachedDisableSwitching == disableSwitching {
+			logger.Process("Reusing cached layer %s", pnpmLayer.Path)
+			logger.Break()
+
+			pnpmLayer.Launch, pnpmLayer.Build, pnpmLayer.Cache = launch, build, build
+
+			err = setLaunchSettings(pnpmLayer, launchSettings, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			return complete()
+		}
+
 		logger.Process("Executing build process")
 
 		pnpmLayer, err = pnpmLayer.Reset()
@@ -94,10 +414,34 @@ func Build(
 
 		pnpmLayer.Launch, pnpmLayer.Build, pnpmLayer.Cache = launch, build, build
 
-		logger.Subprocess("Installing pnpm")
+		install := func() error {
+			return dependencyManager.Deliver(dependency, context.CNBPath, pnpmLayer.Path, context.Platform.Path)
+		}
+
+		switch {
+		case installMode == InstallModeCorepack:
+			logger.Subprocess("Installing pnpm through corepack")
+
+			install = func() error {
+				return installWithCorepack(dependencyManager, corepackExecutable, dependency, context.CNBPath, pnpmLayer.Path, context.Platform.Path)
+			}
+		case distribution == DistributionJS:
+			logger.Subprocess("Installing pnpm (JS distribution)")
+
+			install = func() error {
+				return installJSDistribution(dependencyManager, dependency, context.CNBPath, pnpmLayer.Path, context.Platform.Path)
+			}
+		default:
+			logger.Subprocess("Installing pnpm")
+		}
 
 		duration, err := clock.Measure(func() error {
-			return dependencyManager.Deliver(dependency, context.CNBPath, pnpmLayer.Path, context.Platform.Path)
+			err := install()
+			if err != nil || toolDependency == nil {
+				return err
+			}
+
+			return seedPackageManagerVersion(dependencyManager, *toolDependency, context.CNBPath, pnpmLayer.Path, context.Platform.Path, installMode, distribution)
 		})
 		if err != nil {
 			return packit.BuildResult{}, err
@@ -105,47 +449,521 @@ func Build(
 		logger.Action("Completed in %s", duration.Round(time.Millisecond))
 		logger.Break()
 
-		sbomDisabled, err := checkSbomDisabled()
+		pnpmLayer, err = attachSBOM(pnpmLayer, "pnpm", dependency, context.BuildpackInfo.SBOMFormats, sbomGenerator, clock, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		if sbomDisabled {
-			logger.Subprocess("Skipping SBOM generation for pnpm")
-			logger.Break()
-		} else {
-			logger.GeneratingSBOM(pnpmLayer.Path)
-			var sbomContent sbom.SBOM
-			duration, err = clock.Measure(func() error {
-				sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, pnpmLayer.Path)
-				return err
-			})
-			if err != nil {
-				return packit.BuildResult{}, err
+		pnpmLayer.Metadata = map[string]interface{}{
+			DependencyCacheKey: dependency.Checksum,
+			InstallModeKey:     installMode,
+		}
+
+		if toolDependency != nil {
+			pnpmLayer.Metadata[ToolDependencyCacheKey] = toolDependency.Checksum
+		}
+
+		if disableSwitching {
+			pnpmLayer.Metadata[ManagePackageManagerVersionsKey] = false
+		}
+
+		err = setLaunchSettings(pnpmLayer, launchSettings, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		for name, value := range pnpmEnv {
+			pnpmLayer.SharedEnv.Override(name, value)
+		}
+
+		pnpmLayer.SharedEnv.Prepend("PATH", binPath, string(os.PathListSeparator))
+
+		return complete()
+	}
+}
+
+// requiredConstraints returns the version constraint of the selected, highest
+// priority entry followed by the constraints of every other buildpack that
+// requires pnpm through the build plan. The installed version must satisfy
+// all of them.
+func requiredConstraints(entries []packit.BuildpackPlanEntry) ([]VersionConstraint, error) {
+	var constraints []VersionConstraint
+
+Entries:
+	for i, entry := range entries {
+		version, ok := entry.Metadata["version"].(string)
+		if !ok || version == "" || version == "default" {
+			continue
+		}
+
+		if i > 0 && !isBuildPlanSource(entry) {
+			continue
+		}
+
+		version, err := translateVersionRange(version)
+		if err != nil {
+			return nil, err
+		}
+
+		source, ok := entry.Metadata["version-source"].(string)
+		if !ok {
+			source = "<unknown>"
+		}
+
+		constraint := VersionConstraint{Source: source, Constraint: version}
+		for _, c := range constraints {
+			if c == constraint {
+				continue Entries
 			}
+		}
 
-			logger.Action("Completed in %s", duration.Round(time.Millisecond))
-			logger.Break()
+		constraints = append(constraints, constraint)
+	}
 
-			logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-			pnpmLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
-			if err != nil {
-				return packit.BuildResult{}, err
+	return constraints, nil
+}
+
+// resolveConstraints resolves the highest version of the dependency
+// satisfying all of the given constraints, or the default version when there
+// are none.
+func resolveConstraints(dependencyManager DependencyManager, logger scribe.Emitter, path, id, stack string, constraints []VersionConstraint) (postal.Dependency, error) {
+	switch len(constraints) {
+	case 0:
+		return dependencyManager.Resolve(path, id, "default", stack)
+	case 1:
+		return dependencyManager.Resolve(path, id, constraints[0].Constraint, stack)
+	}
+
+	var requirers []string
+	for _, c := range constraints {
+		requirers = append(requirers, fmt.Sprintf("%s (%s)", c.Constraint, c.Source))
+	}
+	logger.Subprocess("Intersecting version constraints: %s", strings.Join(requirers, ", "))
+
+	dependency, err := dependencyManager.Resolve(path, id, intersectConstraints(constraints), stack)
+	if err != nil {
+		// Every flavor of a dependency, e.g. pnpm-js, shares the name of the
+		// dependency as the prefix of its id.
+		name, _, _ := strings.Cut(id, "-")
+		return postal.Dependency{}, ConstraintConflictError{Dependency: name, Constraints: constraints, Err: err}
+	}
+
+	return dependency, nil
+}
+
+// isBuildPlanSource reports whether the entry was required by another
+// buildpack rather than derived from the application.
+func isBuildPlanSource(entry packit.BuildpackPlanEntry) bool {
+	source, _ := entry.Metadata["version-source"].(string)
+	return source == "" || source == "buildplan"
+}
+
+func isDevEnginesSource(entry packit.BuildpackPlanEntry) bool {
+	source, _ := entry.Metadata["version-source"].(string)
+	return source == "devEngines"
+}
+
+// devEnginesUnsatisfiable reports whether no version of the dependency
+// satisfies the devEngines.packageManager constraint on its own.
+func devEnginesUnsatisfiable(dependencyManager DependencyManager, path, id, stack string, constraints []VersionConstraint) bool {
+	index := slices.IndexFunc(constraints, func(c VersionConstraint) bool {
+		return c.Source == "devEngines"
+	})
+	if index < 0 {
+		return false
+	}
+
+	// The resolution that failed already tried the constraint on its own.
+	if len(constraints) == 1 {
+		return true
+	}
+
+	_, err := dependencyManager.Resolve(path, id, constraints[index].Constraint, stack)
+	return err != nil
+}
+
+// describeConstraints names the versions that the given constraints select,
+// for the message about a devEngines fallback.
+func describeConstraints(constraints []VersionConstraint) string {
+	if len(constraints) == 0 {
+		return "the default version"
+	}
+
+	var sources []string
+	for _, c := range constraints {
+		sources = append(sources, fmt.Sprintf("%s (%s)", c.Constraint, c.Source))
+	}
+
+	return fmt.Sprintf("the version required by %s", strings.Join(sources, ", "))
+}
+
+// checkDevEngines enforces the devEngines.packageManager range of the
+// application when a higher priority source selected the version, according
+// to its onFail field.
+func checkDevEngines(entries []packit.BuildpackPlanEntry, selected packit.BuildpackPlanEntry, version string, logger scribe.Emitter) error {
+	if isDevEnginesSource(selected) {
+		return nil
+	}
+
+	index := slices.IndexFunc(entries, isDevEnginesSource)
+	if index < 0 {
+		return nil
+	}
+
+	devEngines := entries[index]
+	versionRange, _ := devEngines.Metadata["version"].(string)
+	constraint, err := semver.NewConstraint(versionRange)
+	if err != nil {
+		return fmt.Errorf("failed to parse devEngines.packageManager version %q: %w", versionRange, err)
+	}
+
+	selectedVersion, err := semver.NewVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if constraint.Check(selectedVersion) {
+		return nil
+	}
+
+	source, ok := selected.Metadata["version-source"].(string)
+	if !ok {
+		source = "<unknown>"
+	}
+
+	onFail, _ := devEngines.Metadata["on-fail"].(string)
+	switch onFail {
+	case "warn":
+		logger.Process("WARNING: pnpm %s selected from %s does not satisfy %q required by devEngines.packageManager", version, source, versionRange)
+		logger.Break()
+	case "ignore":
+	default:
+		return fmt.Errorf("pnpm %s selected from %s does not satisfy %q required by devEngines.packageManager", version, source, versionRange)
+	}
+
+	return nil
+}
+
+// logOverriddenSources reports the versions requested by lower priority
+// application sources that were discarded in favor of the selected entry.
+func logOverriddenSources(logger scribe.Emitter, selected packit.BuildpackPlanEntry, entries []packit.BuildpackPlanEntry) {
+	selectedSource, ok := selected.Metadata["version-source"].(string)
+	if !ok {
+		selectedSource = "<unknown>"
+	}
+
+	for _, entry := range entries {
+		version, ok := entry.Metadata["version"].(string)
+		if !ok || version == "" {
+			continue
+		}
+
+		source, ok := entry.Metadata["version-source"].(string)
+		if !ok {
+			source = "<unknown>"
+		}
+
+		if source == selectedSource || isBuildPlanSource(entry) {
+			continue
+		}
+
+		logger.Subprocess("Overriding %s version %q (%s takes precedence)", source, version, selectedSource)
+	}
+}
+
+// checkNodeCompatibility verifies that the Node.js version on the PATH is one
+// that the given pnpm version runs on, according to the node-compatibility
+// table of the buildpack.toml. The check is skipped when Node.js is not on
+// the PATH as it may be provided after this buildpack.
+func checkNodeCompatibility(nodeExecutable Executable, buildpackTOMLPath, pnpmVersion string) error {
+	config, err := parseBuildpackTOML(buildpackTOMLPath)
+	if err != nil {
+		return err
+	}
+
+	if len(config.Metadata.NodeCompatibility) == 0 {
+		return nil
+	}
+
+	nodeConstraint, err := config.nodeConstraint(pnpmVersion)
+	if err != nil || nodeConstraint == "" {
+		return err
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err = nodeExecutable.Execute(pexec.Execution{
+		Args:   []string{"--version"},
+		Stdout: buffer,
+		Stderr: buffer,
+	})
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to execute node --version: %w\n%s", err, buffer.String())
+	}
+
+	nodeVersion, err := semver.NewVersion(strings.TrimSpace(buffer.String()))
+	if err != nil {
+		return fmt.Errorf("failed to parse Node.js version %q: %w", strings.TrimSpace(buffer.String()), err)
+	}
+
+	constraint, err := semver.NewConstraint(nodeConstraint)
+	if err != nil {
+		return fmt.Errorf("failed to parse Node.js constraint %q: %w", nodeConstraint, err)
+	}
+
+	if !constraint.Check(nodeVersion) {
+		return fmt.Errorf("pnpm %s requires Node.js %s, but Node.js %s was found on the PATH", pnpmVersion, nodeConstraint, nodeVersion)
+	}
+
+	return nil
+}
+
+// installJSDistribution delivers the npm tarball of pnpm into the layer and
+// links the scripts of its bin directory under their command names, so that
+// they run on the Node.js found on the PATH.
+func installJSDistribution(dependencyManager DependencyManager, dependency postal.Dependency, cnbPath, layerPath, platformPath string) error {
+	err := dependencyManager.Deliver(dependency, cnbPath, layerPath, platformPath)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range []string{"pnpm", "pnpx"} {
+		script := filepath.Join(layerPath, "bin", fmt.Sprintf("%s.cjs", command))
+
+		exists, err := fs.Exists(script)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			if command == "pnpm" {
+				return fmt.Errorf("failed to install pnpm: the npm tarball does not contain bin/pnpm.cjs")
 			}
+
+			continue
 		}
 
-		pnpmLayer.Metadata = map[string]interface{}{
-			DependencyCacheKey: dependency.Checksum,
+		err = os.Symlink(filepath.Base(script), filepath.Join(layerPath, "bin", command))
+		if err != nil {
+			return fmt.Errorf("failed to link %s: %w", command, err)
+		}
+	}
+
+	return nil
+}
+
+// installWithCorepack provisions a corepack home inside the layer that holds
+// the delivered npm tarball in the layout of the corepack cache. It then
+// activates that version and writes the corepack shims into the bin directory
+// of the layer. Network access is disabled so that corepack never downloads a
+// different artifact than the one from the buildpack.toml.
+func installWithCorepack(dependencyManager DependencyManager, corepack Executable, dependency postal.Dependency, cnbPath, layerPath, platformPath string) error {
+	corepackHome := filepath.Join(layerPath, "corepack")
+
+	err := seedCorepackCache(dependencyManager, dependency, cnbPath, corepackHome, platformPath)
+	if err != nil {
+		return err
+	}
+
+	binDir := filepath.Join(layerPath, "bin")
+	err = os.MkdirAll(binDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create corepack shims directory: %w", err)
+	}
+
+	env := append(os.Environ(),
+		fmt.Sprintf("COREPACK_HOME=%s", corepackHome),
+		"COREPACK_ENABLE_NETWORK=0",
+		"COREPACK_ENABLE_DOWNLOAD_PROMPT=0",
+	)
+
+	for _, args := range [][]string{
+		{"prepare", fmt.Sprintf("%s@%s", PnpmDependency, dependency.Version), "--activate"},
+		{"enable", "--install-directory", binDir, PnpmDependency},
+	} {
+		buffer := bytes.NewBuffer(nil)
+		err = corepack.Execute(pexec.Execution{
+			Args:   args,
+			Env:    env,
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return fmt.Errorf("BP_PNPM_INSTALL_MODE=corepack requires corepack on the PATH: %w", err)
+			}
+
+			return fmt.Errorf("failed to execute corepack %s: %w\n%s", strings.Join(args, " "), err, buffer.String())
 		}
+	}
 
-		pnpmLayer.SharedEnv.Prepend("PATH", pnpmLayer.Path, string(os.PathListSeparator))
+	return nil
+}
+
+func checkInstallMode() (string, error) {
+	installMode, ok := os.LookupEnv("BP_PNPM_INSTALL_MODE")
+	if !ok || installMode == "" {
+		return InstallModeStandalone, nil
+	}
+
+	switch installMode {
+	case InstallModeStandalone, InstallModeCorepack:
+		return installMode, nil
+	default:
+		return "", fmt.Errorf("invalid BP_PNPM_INSTALL_MODE value %q: must be one of %q or %q", installMode, InstallModeStandalone, InstallModeCorepack)
+	}
+}
+
+// seedCorepackCache delivers the npm tarball of pnpm into the corepack cache
+// of the given corepack home.
+func seedCorepackCache(dependencyManager DependencyManager, dependency postal.Dependency, cnbPath, corepackHome, platformPath string) error {
+	installFolder := filepath.Join(corepackHome, "v1", PnpmDependency, dependency.Version)
+
+	err := os.MkdirAll(installFolder, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create corepack home: %w", err)
+	}
+
+	err = dependencyManager.Deliver(dependency, cnbPath, installFolder, platformPath)
+	if err != nil {
+		return err
+	}
 
-		return packit.BuildResult{
-			Layers: []packit.Layer{pnpmLayer},
-			Build:  buildMetadata,
-			Launch: launchMetadata,
-		}, nil
+	// corepack only considers a cached version installed when the folder
+	// contains this marker, otherwise it attempts to download it again.
+	marker, err := json.Marshal(map[string]interface{}{
+		"locator": map[string]string{
+			"name":      PnpmDependency,
+			"reference": dependency.Version,
+		},
+		"bin": map[string]string{
+			"pnpm": "./bin/pnpm.cjs",
+			"pnpx": "./bin/pnpx.cjs",
+		},
+		"hash": strings.Replace(dependency.Checksum, ":", ".", 1),
+	})
+	if err != nil {
+		return err
 	}
+
+	err = os.WriteFile(filepath.Join(installFolder, ".corepack"), marker, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write corepack marker: %w", err)
+	}
+
+	return nil
+}
+
+// checkDistribution returns the pnpm distribution to install. corepack only
+// runs the JS distribution, which is therefore the default in that mode.
+func checkDistribution(installMode string) (string, error) {
+	distribution, ok := os.LookupEnv("BP_PNPM_DISTRIBUTION")
+	if !ok || distribution == "" {
+		if installMode == InstallModeCorepack {
+			return DistributionJS, nil
+		}
+
+		return DistributionStandalone, nil
+	}
+
+	switch distribution {
+	case DistributionStandalone, DistributionJS:
+	default:
+		return "", fmt.Errorf("invalid BP_PNPM_DISTRIBUTION value %q: must be one of %q or %q", distribution, DistributionStandalone, DistributionJS)
+	}
+
+	if installMode == InstallModeCorepack && distribution != DistributionJS {
+		return "", fmt.Errorf("BP_PNPM_INSTALL_MODE=corepack requires BP_PNPM_DISTRIBUTION=%s", DistributionJS)
+	}
+
+	return distribution, nil
+}
+
+// checkStaticExecutable reports whether the statically linked standalone
+// executable must be installed instead of the one linked against glibc. Unless
+// BP_PNPM_STATIC says otherwise, this is the case when the target declares a
+// distribution that ships musl, or, when it declares none, when the build
+// image ships musl, provided that the buildpack.toml lists the statically
+// linked executable.
+func checkStaticExecutable(buildpackTOMLPath string, logger scribe.Emitter) (bool, error) {
+	if staticStr, ok := os.LookupEnv("BP_PNPM_STATIC"); ok {
+		static, err := strconv.ParseBool(staticStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_PNPM_STATIC value %s: %w", staticStr, err)
+		}
+		return static, nil
+	}
+
+	var musl bool
+	if distro, ok := os.LookupEnv("CNB_TARGET_DISTRO_NAME"); ok && distro != "" {
+		musl = slices.Contains(muslDistros, distro)
+	} else {
+		// The lifecycle only sets CNB_TARGET_DISTRO_NAME for buildpacks of API
+		// 0.10 and later, so the libc of the build image, which shares the
+		// distribution of the run image, decides otherwise.
+		loaders, err := filepath.Glob(muslLoaders)
+		if err != nil {
+			return false, err
+		}
+
+		musl = len(loaders) > 0
+	}
+
+	if !musl {
+		return false, nil
+	}
+
+	config, err := parseBuildpackTOML(buildpackTOMLPath)
+	if err != nil {
+		return false, err
+	}
+
+	if !config.hasDependency(PnpmStaticDependency) {
+		logger.Subprocess("WARNING: the target ships musl, but buildpack.toml lists no statically linked pnpm executable")
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// attachSBOM generates the SBOM of the dependency installed into the layer in
+// the given formats, unless BP_DISABLE_SBOM is set.
+func attachSBOM(layer packit.Layer, name string, dependency postal.Dependency, formats []string, sbomGenerator SBOMGenerator, clock chronos.Clock, logger scribe.Emitter) (packit.Layer, error) {
+	sbomDisabled, err := checkSbomDisabled()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if sbomDisabled {
+		logger.Subprocess("Skipping SBOM generation for %s", name)
+		logger.Break()
+
+		return layer, nil
+	}
+
+	logger.GeneratingSBOM(layer.Path)
+	var sbomContent sbom.SBOM
+	duration, err := clock.Measure(func() error {
+		sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, layer.Path)
+		return err
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	logger.FormattingSBOM(formats...)
+	layer.SBOM, err = sbomContent.InFormats(formats...)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	return layer, nil
 }
 
 func checkSbomDisabled() (bool, error) {
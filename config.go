@@ -0,0 +1,125 @@
+package pnpm
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+// rcBindings lists the types of the service bindings that hold registry
+// configuration, along with the entry of the binding it is read from. The
+// pnpm-specific configuration comes last so that it takes precedence.
+var rcBindings = []struct {
+	Type  string
+	Entry string
+}{
+	{Type: "npmrc", Entry: ".npmrc"},
+	{Type: "pnpmrc", Entry: "rc"},
+}
+
+// installConfig contributes the layer that holds the npm configuration of
+// the build: the registry configuration of the npmrc and pnpmrc service
+// bindings, written into a single rc file that pnpm and npm read as both their
+// global and user configuration, and the given settings, exported as
+// environment variables. The layer is neither cached nor part of the image,
+// so that registry credentials never end up in either. It returns no layer
+// when there is no configuration.
+func installConfig(context packit.BuildContext, bindingResolver BindingResolver, settings map[string]string, logger scribe.Emitter) ([]packit.Layer, error) {
+	var (
+		config bytes.Buffer
+		names  []string
+	)
+
+	for _, rc := range rcBindings {
+		bindings, err := bindingResolver.Resolve(rc.Type, "", context.Platform.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s service bindings: %w", rc.Type, err)
+		}
+
+		slices.SortFunc(bindings, func(a, b servicebindings.Binding) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		for _, binding := range bindings {
+			entry, ok := binding.Entries[rc.Entry]
+			if !ok {
+				return nil, fmt.Errorf("%s service binding %q has no %s entry", rc.Type, binding.Name, rc.Entry)
+			}
+
+			content, err := entry.ReadBytes()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s entry of service binding %q: %w", rc.Entry, binding.Name, err)
+			}
+
+			fmt.Fprintf(&config, "; from the %s service binding %s\n", rc.Type, binding.Name)
+			config.Write(content)
+			if !bytes.HasSuffix(content, []byte("\n")) {
+				config.WriteString("\n")
+			}
+
+			names = append(names, fmt.Sprintf("%s (%s)", binding.Name, rc.Type))
+		}
+	}
+
+	if len(names) == 0 && len(settings) == 0 {
+		return nil, nil
+	}
+
+	configLayer, err := context.Layers.Get(PnpmConfigLayerName)
+	if err != nil {
+		return nil, err
+	}
+
+	configLayer, err = configLayer.Reset()
+	if err != nil {
+		return nil, err
+	}
+
+	configLayer.Launch, configLayer.Build, configLayer.Cache = false, true, false
+
+	if len(names) > 0 {
+		logger.Process("Configuring the registry from service bindings")
+		for _, name := range names {
+			logger.Subprocess("Using %s", name)
+		}
+		logger.Break()
+
+		rcPath := filepath.Join(configLayer.Path, "npmrc")
+		err = os.WriteFile(rcPath, config.Bytes(), 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write registry configuration: %w", err)
+		}
+
+		configLayer.BuildEnv.Override("NPM_CONFIG_GLOBALCONFIG", rcPath)
+		configLayer.BuildEnv.Override("npm_config_userconfig", rcPath)
+	}
+
+	for name, value := range settings {
+		configLayer.BuildEnv.Override(name, value)
+	}
+
+	return []packit.Layer{configLayer}, nil
+}
+
+// environmentOverrides returns the variables that the layer overrides for
+// later buildpacks, in the form of os.Environ.
+func environmentOverrides(layer packit.Layer) []string {
+	var env []string
+	for _, environment := range []packit.Environment{layer.SharedEnv, layer.BuildEnv} {
+		for _, key := range slices.Sorted(maps.Keys(environment)) {
+			if name, ok := strings.CutSuffix(key, ".override"); ok {
+				env = append(env, fmt.Sprintf("%s=%s", name, environment[key]))
+			}
+		}
+	}
+
+	return env
+}
@@ -1,7 +1,32 @@
 package pnpm
 
 const (
-	PnpmLayerName      = "pnpm"
-	PnpmDependency     = "pnpm"
-	DependencyCacheKey = "dependency-sha"
+	PnpmLayerName        = "pnpm"
+	NodeLayerName        = "node"
+	PnpmConfigLayerName  = "pnpm-config"
+	PnpmDependency       = "pnpm"
+	PnpmJSDependency     = "pnpm-js"
+	PnpmStaticDependency = "pnpm-static"
+	NodeDependency       = "node"
+	DependencyCacheKey   = "dependency-sha"
+	InstallModeKey       = "install-mode"
+
+	ToolDependencyCacheKey          = "tool-dependency-sha"
+	ManagePackageManagerVersionsKey = "manage-package-manager-versions"
+
+	PnpmStoreLayerName  = "pnpm-store"
+	PnpmStoreDependency = "pnpm-store"
+	StoreVersionKey     = "store-version"
+	PnpmMajorKey        = "pnpm-major"
+	LockfileCacheKey    = "lockfile-sha"
+	StoreSizeKey        = "store-size"
+	BuildsSincePruneKey = "builds-since-prune"
+
+	InstallModeStandalone = "standalone"
+	InstallModeCorepack   = "corepack"
+
+	DistributionStandalone = "standalone"
+	DistributionJS         = "js"
+
+	VersionedCommandDir = "command"
 )
@@ -0,0 +1,58 @@
+package pnpm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VersionConstraint is a pnpm version constraint along with the
+// version-source that required it.
+type VersionConstraint struct {
+	Source     string
+	Constraint string
+}
+
+// ConstraintConflictError is returned when no version of the dependency
+// satisfies every constraint required through the buildpack plan.
+type ConstraintConflictError struct {
+	Dependency  string
+	Constraints []VersionConstraint
+	Err         error
+}
+
+func (e ConstraintConflictError) Error() string {
+	var requirers []string
+	for _, c := range e.Constraints {
+		requirers = append(requirers, fmt.Sprintf("%s requires %q", c.Source, c.Constraint))
+	}
+
+	return fmt.Sprintf("no %s version satisfies all of the required constraints (%s): %s", e.Dependency, strings.Join(requirers, ", "), e.Err)
+}
+
+func (e ConstraintConflictError) Unwrap() error {
+	return e.Err
+}
+
+// intersectConstraints combines the given constraints into a single
+// constraint that is only satisfied by versions satisfying all of them. As
+// "||" binds looser than "," the alternatives of every constraint are
+// distributed over each other.
+func intersectConstraints(constraints []VersionConstraint) string {
+	alternatives := []string{""}
+	for _, c := range constraints {
+		var combined []string
+		for _, prefix := range alternatives {
+			for _, alternative := range strings.Split(c.Constraint, "||") {
+				alternative = strings.TrimSpace(alternative)
+				if prefix != "" {
+					alternative = prefix + ", " + alternative
+				}
+
+				combined = append(combined, alternative)
+			}
+		}
+		alternatives = combined
+	}
+
+	return strings.Join(alternatives, " || ")
+}
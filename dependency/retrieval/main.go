@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/paketo-buildpacks/libdependency/buildpack_config"
 	"github.com/paketo-buildpacks/libdependency/github"
 	"github.com/paketo-buildpacks/libdependency/retrieve"
 	"github.com/paketo-buildpacks/libdependency/versionology"
@@ -18,29 +22,115 @@ type Asset struct {
 	Digest             string `json:"digest"`
 }
 
-func main() {
-	retrieve.NewMetadataWithPlatforms("pnpm", getAllVersions, generateMetadataWithPlatform)
+type NpmPackageVersion struct {
+	Dist struct {
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity"`
+	} `json:"dist"`
 }
 
-func generateMetadataWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform) ([]versionology.Dependency, error) {
+// dependencyIDs lists the flavors of pnpm that the buildpack.toml lists,
+// each retrieved separately so that a flavor added after some pnpm versions
+// were listed still gets an entry for them.
+var dependencyIDs = []string{"pnpm", "pnpm-static", "pnpm-js"}
+
+func main() {
+	buildpackTOMLPath, output := retrieve.FetchArgs()
+	if output == "" {
+		panic("output is required")
+	}
+
+	config, err := buildpack_config.ParseBuildpackToml(buildpackTOMLPath)
+	if err != nil {
+		panic(err)
+	}
+
+	// Like retrieve.NewMetadataWithPlatforms, which only looks for versions
+	// that are new to a single id.
+	if len(config.Targets) == 0 {
+		config.Targets = []cargo.ConfigTarget{{OS: "linux", Arch: "amd64"}}
+	}
+
+	allVersions, err := getAllVersions()
+	if err != nil {
+		panic(err)
+	}
+
+	newVersions := map[string]versionology.VersionFetcherArray{}
+	for _, id := range dependencyIDs {
+		newVersions[id], err = retrieve.GetNewVersionsForId(id, config, func() (versionology.VersionFetcherArray, error) {
+			return allVersions, nil
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var dependencies []versionology.Dependency
+	for _, target := range config.Targets {
+		platform := retrieve.Platform{OS: target.OS, Arch: target.Arch}
+		for _, id := range dependencyIDs {
+			for _, versionFetcher := range newVersions[id] {
+				dependency, err := generateMetadataWithPlatform(id, versionFetcher, platform)
+				if errors.As(err, &NoSourceCodeError{}) {
+					fmt.Printf("Skipping %s %s for platform %s/%s: %s\n", id, versionFetcher.Version().String(), platform.OS, platform.Arch, err)
+					continue
+				}
+				if err != nil {
+					panic(err)
+				}
+
+				fmt.Printf("Generating metadata for %s %s, platform %s/%s\n", id, versionFetcher.Version().String(), platform.OS, platform.Arch)
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+
+	metadataJSON, err := json.Marshal(dependencies)
+	if err != nil {
+		panic(fmt.Errorf("unable to marshall metadata json, with error=%w", err))
+	}
+
+	err = os.WriteFile(output, metadataJSON, os.ModePerm)
+	if err != nil {
+		panic(fmt.Errorf("cannot write to %s: %w", output, err))
+	}
+	fmt.Printf("Wrote metadata to %s\n", output)
+}
 
-	dependency, err := createDependencyVersionWithPlatform(versionFetcher, platform)
+// generateMetadataWithPlatform describes the given flavor of a pnpm version.
+// Older releases do not publish statically linked executables, for which it
+// returns a NoSourceCodeError.
+func generateMetadataWithPlatform(id string, versionFetcher versionology.VersionFetcher, platform retrieve.Platform) (versionology.Dependency, error) {
+	var (
+		dependency cargo.ConfigMetadataDependency
+		err        error
+	)
+	switch id {
+	case "pnpm-js":
+		dependency, err = createJSDependencyVersionWithPlatform(versionFetcher, platform)
+	default:
+		dependency, err = createDependencyVersionWithPlatform(versionFetcher, platform, id == "pnpm-static")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not create pnpm version: %w", err)
+		return versionology.Dependency{}, fmt.Errorf("could not create %s version: %w", id, err)
 	}
 
-	return []versionology.Dependency{{
+	return versionology.Dependency{
 		ConfigMetadataDependency: dependency,
 		SemverVersion:            versionFetcher.Version(),
 		Target:                   "bionic",
-	}}, nil
+	}, nil
 }
 
 func getAllVersions() (versionology.VersionFetcherArray, error) {
 	return github.GetAllVersions(os.Getenv("GITHUB_TOKEN"), "pnpm", "pnpm")()
 }
 
-func createDependencyVersionWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform) (cargo.ConfigMetadataDependency, error) {
+// createDependencyVersionWithPlatform describes the standalone executable of
+// the given pnpm version. When static is set, it describes the statically
+// linked executable that runs on targets without glibc instead.
+func createDependencyVersionWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform, static bool) (cargo.ConfigMetadataDependency, error) {
 	webClient := NewWebClient()
 	githubClient := NewGithubClient(webClient)
 
@@ -65,7 +155,13 @@ func createDependencyVersionWithPlatform(versionFetcher versionology.VersionFetc
 		return cargo.ConfigMetadataDependency{}, fmt.Errorf("failed to find architecture name: %w", err)
 	}
 
+	id := "pnpm"
 	assetName := fmt.Sprintf("pnpm-%s-%s", platform.OS, arch)
+	if static {
+		id = "pnpm-static"
+		assetName = fmt.Sprintf("pnpm-%sstatic-%s", platform.OS, arch)
+	}
+
 	assetUrl, err := githubClient.DownloadReleaseAsset("pnpm", "pnpm", tagName, assetName, releaseAssetPath)
 	if err != nil {
 		if errors.Is(err, AssetNotFound{AssetName: assetName}) {
@@ -94,7 +190,7 @@ func createDependencyVersionWithPlatform(versionFetcher versionology.VersionFetc
 		Arch:            platform.Arch,
 		CPE:             fmt.Sprintf("cpe:2.3:a:pnpm:pnpm:%s:*:*:*:*:*:*:*", version),
 		Checksum:        dependencySHA,
-		ID:              "pnpm",
+		ID:              id,
 		Licenses:        []interface{}{"MIT"},
 		Name:            "pnpm",
 		OS:              platform.OS,
@@ -109,6 +205,78 @@ func createDependencyVersionWithPlatform(versionFetcher versionology.VersionFetc
 	}, nil
 }
 
+// createJSDependencyVersionWithPlatform describes the npm tarball of the
+// given pnpm version. The tarball is the same for every platform, but is
+// listed once per platform so that it resolves on every target.
+func createJSDependencyVersionWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform) (cargo.ConfigMetadataDependency, error) {
+	webClient := NewWebClient()
+
+	version := versionFetcher.Version().String()
+
+	body, err := webClient.Get(fmt.Sprintf("https://registry.npmjs.org/pnpm/%s", version))
+	if err != nil {
+		return cargo.ConfigMetadataDependency{}, fmt.Errorf("could not get npm package version: %w", err)
+	}
+
+	var packageVersion NpmPackageVersion
+	err = json.Unmarshal(body, &packageVersion)
+	if err != nil {
+		return cargo.ConfigMetadataDependency{}, fmt.Errorf("could not unmarshal npm package version: %w\n%s", err, body)
+	}
+
+	if packageVersion.Dist.Tarball == "" {
+		return cargo.ConfigMetadataDependency{}, NoSourceCodeError{Version: version}
+	}
+
+	dependencySHA, err := npmIntegrityChecksum(packageVersion.Dist.Integrity)
+	if err != nil {
+		return cargo.ConfigMetadataDependency{}, fmt.Errorf("could not read the integrity of pnpm %s: %w", version, err)
+	}
+
+	return cargo.ConfigMetadataDependency{
+		Arch:            platform.Arch,
+		CPE:             fmt.Sprintf("cpe:2.3:a:pnpm:pnpm:%s:*:*:*:*:*:*:*", version),
+		Checksum:        dependencySHA,
+		ID:              "pnpm-js",
+		Licenses:        []interface{}{"MIT"},
+		Name:            "pnpm",
+		OS:              platform.OS,
+		PURL:            retrieve.GeneratePURL("pnpm", version, dependencySHA, packageVersion.Dist.Tarball),
+		Source:          packageVersion.Dist.Tarball,
+		SourceChecksum:  dependencySHA,
+		Stacks:          []string{"*"},
+		URI:             packageVersion.Dist.Tarball,
+		Version:         version,
+		DeprecationDate: nil,
+		StripComponents: 1,
+	}, nil
+}
+
+// npmIntegrityChecksum converts the sha512 hash of the Subresource Integrity
+// string that the npm registry publishes for a tarball, e.g.
+// "sha512-<base64>", to a checksum of the form "sha512:<hex>". The
+// packageManager field of package.json pins pnpm to the same hash, against
+// which the buildpack verifies this checksum.
+func npmIntegrityChecksum(integrity string) (string, error) {
+	// Subresource Integrity strings may list several hashes separated by
+	// whitespace.
+	for _, hash := range strings.Fields(integrity) {
+		algorithm, encoded, found := strings.Cut(hash, "-")
+		if !found || algorithm != "sha512" {
+			continue
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode %q: %w", hash, err)
+		}
+
+		return fmt.Sprintf("sha512:%s", hex.EncodeToString(digest)), nil
+	}
+
+	return "", fmt.Errorf("no sha512 hash in %q", integrity)
+}
+
 func archName(platform retrieve.Platform) (string, error) {
 	switch platform.Arch {
 	case "amd64":
@@ -1,15 +1,512 @@
 package pnpm
 
-import "github.com/paketo-buildpacks/packit/v2"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+type BuildPlanMetadata struct {
+	Version       string `toml:"version,omitempty"`
+	VersionSource string `toml:"version-source,omitempty"`
+	Build         bool   `toml:"build,omitempty"`
+	Launch        bool   `toml:"launch,omitempty"`
+	OnFail        string `toml:"on-fail,omitempty"`
+	Integrity     string `toml:"integrity,omitempty"`
+}
+
+func Detect(logger scribe.Emitter) packit.DetectFunc {
+	packageJSONParser := NewPackageJSONParser()
+	pnpmLockParser := NewPnpmLockParser()
+	miseTOMLParser := NewMiseTOMLParser()
+	toolVersionsParser := NewToolVersionsParser()
 
-func Detect() packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
-		return packit.DetectResult{
-			Plan: packit.BuildPlan{
-				Provides: []packit.BuildPlanProvision{
-					{Name: PnpmDependency},
-				},
+		plan := packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: PnpmDependency},
 			},
+		}
+
+		projectPath, err := findProjectPath(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		workspaceRoot, err := findWorkspaceRoot(projectPath, context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		dirs := searchDirs(projectPath, workspaceRoot)
+
+		var packageManager PackageManager
+		for _, dir := range dirs {
+			packageManager, err = packageJSONParser.ParsePackageManager(filepath.Join(dir, "package.json"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if packageManager.Name != "" {
+				break
+			}
+		}
+
+		var devEngine DevEngine
+		for _, dir := range dirs {
+			devEngine, err = packageJSONParser.ParseDevEngines(filepath.Join(dir, "package.json"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if devEngine.Name != "" {
+				break
+			}
+		}
+
+		if name := declaredPackageManager(packageManager, devEngine); name != "" && name != PnpmDependency {
+			logger.Process("Not requiring pnpm: package.json declares %s as its package manager", name)
+			logger.Break()
+
+			return packit.DetectResult{
+				Plan: plan,
+			}, nil
+		}
+
+		lockfiles, err := findOtherLockfiles(dirs)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if len(lockfiles) > 0 {
+			hasPnpmLock, err := fs.Exists(filepath.Join(workspaceRoot, "pnpm-lock.yaml"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			switch {
+			case !hasPnpmLock && declaredPackageManager(packageManager, devEngine) != PnpmDependency:
+				logger.Process("Not requiring pnpm: found %s but no pnpm-lock.yaml", strings.Join(lockfiles, ", "))
+				logger.Break()
+
+				return packit.DetectResult{
+					Plan: plan,
+				}, nil
+
+			case hasPnpmLock:
+				strict, err := checkStrictEnabled()
+				if err != nil {
+					return packit.DetectResult{}, err
+				}
+
+				if strict {
+					return packit.DetectResult{}, fmt.Errorf("found %s next to pnpm-lock.yaml: remove the lockfiles of other package managers or unset BP_PNPM_STRICT", strings.Join(lockfiles, ", "))
+				}
+
+				logger.Process("WARNING: found %s next to pnpm-lock.yaml, set BP_PNPM_STRICT=true to fail the build instead", strings.Join(lockfiles, ", "))
+				logger.Break()
+			}
+		}
+
+		if version, ok := os.LookupEnv("BP_PNPM_VERSION"); ok {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: "BP_PNPM_VERSION",
+				},
+			})
+		}
+
+		if packageManager.Name == PnpmDependency {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       packageManager.Version,
+					VersionSource: "package.json",
+					Integrity:     packageManager.Integrity,
+				},
+			})
+		}
+
+		var voltaVersion string
+		for _, dir := range dirs {
+			voltaVersion, err = packageJSONParser.ParseVolta(filepath.Join(dir, "package.json"), workspaceRoot)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if voltaVersion != "" {
+				break
+			}
+		}
+
+		if voltaVersion != "" {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       voltaVersion,
+					VersionSource: "volta",
+				},
+			})
+		}
+
+		var miseVersion string
+	Mise:
+		for _, dir := range dirs {
+			for _, name := range []string{"mise.toml", ".mise.toml"} {
+				miseVersion, err = miseTOMLParser.ParseVersion(filepath.Join(dir, name))
+				if err != nil {
+					return packit.DetectResult{}, err
+				}
+
+				if miseVersion != "" {
+					break Mise
+				}
+			}
+		}
+
+		if miseVersion != "" {
+			version, err := translateVersionRange(miseVersion)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: "mise.toml",
+				},
+			})
+		}
+
+		var toolVersion string
+		for _, dir := range dirs {
+			toolVersion, err = toolVersionsParser.ParseVersion(filepath.Join(dir, ".tool-versions"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if toolVersion != "" {
+				break
+			}
+		}
+
+		if toolVersion != "" {
+			version, err := translateVersionRange(toolVersion)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: ".tool-versions",
+				},
+			})
+		}
+
+		if devEngine.Name == PnpmDependency {
+			version, err := translateVersionRange(devEngine.Version)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: "devEngines",
+					OnFail:        devEngine.OnFail,
+				},
+			})
+		}
+
+		var engine string
+		for _, dir := range dirs {
+			engine, err = packageJSONParser.ParseEngines(filepath.Join(dir, "package.json"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if engine != "" {
+				break
+			}
+		}
+
+		if engine != "" {
+			version, err := translateVersionRange(engine)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: "engines",
+				},
+			})
+		}
+
+		lockfileVersion, err := pnpmLockParser.ParseVersion(filepath.Join(workspaceRoot, "pnpm-lock.yaml"))
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if lockfileVersion != "" {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       lockfileVersion,
+					VersionSource: "pnpm-lock.yaml",
+				},
+			})
+		}
+
+		requiresPnpm, err := usesPnpm(workspaceRoot)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if requiresPnpm {
+			launch, err := checkLaunchEnabled()
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: PnpmDependency,
+				Metadata: BuildPlanMetadata{
+					Build:  true,
+					Launch: launch,
+				},
+			})
+		}
+
+		installMode, err := checkInstallMode()
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		distribution, err := checkDistribution(installMode)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		provideNode, err := checkProvideNodeEnabled()
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		// Node.js is installed through pnpm env, which only the standalone
+		// distribution can run without node.
+		if provideNode && (installMode == InstallModeCorepack || distribution == DistributionJS) {
+			return packit.DetectResult{}, errors.New("BP_PNPM_PROVIDE_NODE requires the standalone distribution of pnpm, which installs Node.js through pnpm env: unset BP_PNPM_PROVIDE_NODE or use BP_PNPM_INSTALL_MODE=standalone and BP_PNPM_DISTRIBUTION=standalone")
+		}
+
+		nodeRequirement, err := nodeRequirement(filepath.Join(context.CNBPath, "buildpack.toml"), plan.Requires)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		switch {
+		case nodeRequirement == nil:
+		case distribution == DistributionJS, provideNode:
+			// The JS distribution cannot run without node, and a node provided by
+			// this buildpack always satisfies the requirement.
+			plan.Requires = append(plan.Requires, *nodeRequirement)
+		case nodeRequirement.Metadata.(BuildPlanMetadata).Version != "":
+			// The plan without the node requirement remains as an alternative so
+			// that detection still passes when no buildpack provides node.
+			withNode := plan
+			withNode.Requires = append(append([]packit.BuildPlanRequirement{}, plan.Requires...), *nodeRequirement)
+			withNode.Or = []packit.BuildPlan{plan}
+			plan = withNode
+		}
+
+		if version, ok := os.LookupEnv("BP_NODE_VERSION"); ok && provideNode && nodeRequirement != nil {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: NodeDependency,
+				Metadata: BuildPlanMetadata{
+					Version:       version,
+					VersionSource: "BP_NODE_VERSION",
+					Build:         true,
+				},
+			})
+		}
+
+		// Node.js is only provided along with the requirements it satisfies.
+		if provideNode && slices.ContainsFunc(plan.Requires, func(r packit.BuildPlanRequirement) bool { return r.Name == NodeDependency }) {
+			plan.Provides = append(plan.Provides, packit.BuildPlanProvision{Name: NodeDependency})
+		}
+
+		// Every provision of a plan has to be required, so the pnpm store is only
+		// provided by alternatives for the buildpacks that require it.
+		alternatives := append([]packit.BuildPlan{plan}, plan.Or...)
+		for _, alternative := range alternatives {
+			alternative.Provides = append(append([]packit.BuildPlanProvision{}, alternative.Provides...), packit.BuildPlanProvision{Name: PnpmStoreDependency})
+			alternative.Or = nil
+			plan.Or = append(plan.Or, alternative)
+		}
+
+		return packit.DetectResult{
+			Plan: plan,
 		}, nil
 	}
 }
+
+// nodeRequirement returns a requirement on the Node.js versions that the
+// pnpm version selected by the highest priority requirement runs on,
+// according to the node-compatibility table of the buildpack.toml. The
+// requirement has no version when the table has no matching entry. It
+// returns nil when pnpm itself is not required.
+func nodeRequirement(buildpackTOMLPath string, requires []packit.BuildPlanRequirement) (*packit.BuildPlanRequirement, error) {
+	if len(requires) == 0 {
+		return nil, nil
+	}
+
+	config, err := parseBuildpackTOML(buildpackTOMLPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		constraint string
+		launch     bool
+	)
+	for _, requirement := range requires {
+		metadata := requirement.Metadata.(BuildPlanMetadata)
+		if constraint == "" && metadata.Version != "" {
+			constraint, err = translateVersionRange(metadata.Version)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		launch = launch || metadata.Launch
+	}
+
+	requirement := packit.BuildPlanRequirement{
+		Name: NodeDependency,
+		Metadata: BuildPlanMetadata{
+			Build:  true,
+			Launch: launch,
+		},
+	}
+
+	if len(config.Metadata.NodeCompatibility) == 0 {
+		return &requirement, nil
+	}
+
+	pnpmVersion, err := config.highestPnpmVersion(constraint)
+	if err != nil || pnpmVersion == "" {
+		return &requirement, err
+	}
+
+	nodeVersion, err := config.nodeConstraint(pnpmVersion)
+	if err != nil || nodeVersion == "" {
+		return &requirement, err
+	}
+
+	requirement.Metadata = BuildPlanMetadata{
+		Version:       nodeVersion,
+		VersionSource: "pnpm",
+		Build:         true,
+		Launch:        launch,
+	}
+
+	return &requirement, nil
+}
+
+// usesPnpm reports whether the application is managed by pnpm, in which case
+// the buildpack requires pnpm itself instead of relying on another buildpack
+// to do so.
+func usesPnpm(workspaceRoot string) (bool, error) {
+	for _, name := range []string{"pnpm-lock.yaml", "pnpm-workspace.yaml"} {
+		exists, err := fs.Exists(filepath.Join(workspaceRoot, name))
+		if err != nil {
+			return false, err
+		}
+
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// declaredPackageManager returns the name of the package manager the
+// application declares in its package.json, if any.
+func declaredPackageManager(packageManager PackageManager, devEngine DevEngine) string {
+	if packageManager.Name != "" {
+		return packageManager.Name
+	}
+
+	return devEngine.Name
+}
+
+// findOtherLockfiles returns the lockfiles written by package managers other
+// than pnpm that are present in any of the given directories.
+func findOtherLockfiles(dirs []string) ([]string, error) {
+	var lockfiles []string
+	for _, name := range []string{"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "bun.lock", "bun.lockb"} {
+		for _, dir := range dirs {
+			exists, err := fs.Exists(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				lockfiles = append(lockfiles, name)
+				break
+			}
+		}
+	}
+
+	return lockfiles, nil
+}
+
+func checkStrictEnabled() (bool, error) {
+	if strictStr, ok := os.LookupEnv("BP_PNPM_STRICT"); ok {
+		strict, err := strconv.ParseBool(strictStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_PNPM_STRICT value %s: %w", strictStr, err)
+		}
+		return strict, nil
+	}
+	return false, nil
+}
+
+func checkProvideNodeEnabled() (bool, error) {
+	if provideStr, ok := os.LookupEnv("BP_PNPM_PROVIDE_NODE"); ok {
+		provide, err := strconv.ParseBool(provideStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_PNPM_PROVIDE_NODE value %s: %w", provideStr, err)
+		}
+		return provide, nil
+	}
+	return false, nil
+}
+
+func checkLaunchEnabled() (bool, error) {
+	if launchStr, ok := os.LookupEnv("BP_PNPM_LAUNCH"); ok {
+		launch, err := strconv.ParseBool(launchStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_PNPM_LAUNCH value %s: %w", launchStr, err)
+		}
+		return launch, nil
+	}
+	return false, nil
+}
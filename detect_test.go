@@ -1,10 +1,13 @@
 package pnpm_test
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/paketo-buildpacks/pnpm"
 	"github.com/sclevine/spec"
 
@@ -16,6 +19,8 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		Expect = NewWithT(t).Expect
 
 		workingDir string
+		cnbDir     string
+		buffer     *bytes.Buffer
 		detect     packit.DetectFunc
 	)
 
@@ -24,7 +29,10 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		workingDir, err = os.MkdirTemp("", "working-dir")
 		Expect(err).NotTo(HaveOccurred())
 
-		detect = pnpm.Detect()
+		cnbDir = t.TempDir()
+
+		buffer = bytes.NewBuffer(nil)
+		detect = pnpm.Detect(scribe.NewEmitter(buffer))
 	})
 
 	it.After(func() {
@@ -34,6 +42,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 	it("provides pnpm as a dependency", func() {
 		result, err := detect(packit.DetectContext{
 			WorkingDir: workingDir,
+			CNBPath:    cnbDir,
 		})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(result).To(Equal(packit.DetectResult{
@@ -41,7 +50,1056 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 				Provides: []packit.BuildPlanProvision{
 					{Name: "pnpm"},
 				},
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: "pnpm"},
+							{Name: "pnpm-store"},
+						},
+					},
+				},
 			},
 		}))
 	})
+
+	context("when BP_PNPM_VERSION is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_PNPM_VERSION", "9.12.*")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_PNPM_VERSION")).To(Succeed())
+		})
+
+		it("requires the given version of pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "9.12.*",
+						VersionSource: "BP_PNPM_VERSION",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when package.json declares pnpm as its packageManager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"packageManager": "pnpm@9.12.3+sha512.abc123"
+			}`), 0600)).To(Succeed())
+		})
+
+		it("requires the declared version of pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pnpm"},
+					},
+					Requires: []packit.BuildPlanRequirement{
+						{
+							Name: "pnpm",
+							Metadata: pnpm.BuildPlanMetadata{
+								Version:       "9.12.3",
+								VersionSource: "package.json",
+								Integrity:     "sha512.abc123",
+							},
+						},
+					},
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+								{Name: "pnpm-store"},
+							},
+							Requires: []packit.BuildPlanRequirement{
+								{
+									Name: "pnpm",
+									Metadata: pnpm.BuildPlanMetadata{
+										Version:       "9.12.3",
+										VersionSource: "package.json",
+										Integrity:     "sha512.abc123",
+									},
+								},
+							},
+						},
+					},
+				},
+			}))
+		})
+	})
+
+	context("when package.json declares another packageManager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"packageManager": "yarn@4.1.0"
+			}`), 0600)).To(Succeed())
+		})
+
+		it("does not require pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Not requiring pnpm: package.json declares yarn as its package manager"))
+		})
+
+		context("even when there is a pnpm-lock.yaml", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '9.0'\n"), 0600)).To(Succeed())
+			})
+
+			it("does not require pnpm", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when package.json declares another package manager in devEngines.packageManager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"devEngines": {"packageManager": {"name": "npm", "version": "^10"}}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("does not require pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Not requiring pnpm: package.json declares npm as its package manager"))
+		})
+	})
+
+	context("when the app only has the lockfile of another package manager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"engines": {"pnpm": "9.x"}}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{}`), 0600)).To(Succeed())
+		})
+
+		it("does not require pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pnpm"},
+					},
+				},
+			}))
+			Expect(buffer.String()).To(ContainSubstring("Not requiring pnpm: found package-lock.json but no pnpm-lock.yaml"))
+		})
+	})
+
+	context("when devEngines declares pnpm next to the lockfile of another package manager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"devEngines": {"packageManager": {"name": "pnpm", "version": "^10"}}}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{}`), 0600)).To(Succeed())
+		})
+
+		it("still requires pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "pnpm",
+				Metadata: pnpm.BuildPlanMetadata{
+					Version:       "^10",
+					VersionSource: "devEngines",
+					OnFail:        "error",
+				},
+			}))
+			Expect(buffer.String()).NotTo(ContainSubstring("Not requiring pnpm"))
+		})
+	})
+
+	context("when the lockfile of another package manager sits next to pnpm-lock.yaml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '9.0'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), nil, 0600)).To(Succeed())
+		})
+
+		it("warns and still requires pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "pnpm",
+				Metadata: pnpm.BuildPlanMetadata{
+					Build: true,
+				},
+			}))
+			Expect(buffer.String()).To(ContainSubstring("WARNING: found yarn.lock next to pnpm-lock.yaml"))
+		})
+
+		context("when BP_PNPM_STRICT is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_PNPM_STRICT", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_STRICT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError("found yarn.lock next to pnpm-lock.yaml: remove the lockfiles of other package managers or unset BP_PNPM_STRICT"))
+			})
+		})
+	})
+
+	context("when package.json pins pnpm with volta", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"volta": {"node": "20.11.0", "pnpm": "8.15.4"}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("requires the pinned version of pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "8.15.4",
+						VersionSource: "volta",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when mise.toml pins pnpm", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".mise.toml"), []byte("[tools]\npnpm = \"latest\"\n"), 0600)).To(Succeed())
+		})
+
+		it("requires the pinned version of pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "*",
+						VersionSource: "mise.toml",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when .tool-versions pins pnpm", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".tool-versions"), []byte("pnpm 8.15.4\n"), 0600)).To(Succeed())
+		})
+
+		it("requires the pinned version of pnpm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "8.15.4",
+						VersionSource: ".tool-versions",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when package.json declares pnpm in devEngines.packageManager", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"devEngines": {
+					"packageManager": {"name": "pnpm", "version": ">= 9.1  <10", "onFail": "error"}
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("requires a pnpm version satisfying the range", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       ">= 9.1 <10",
+						VersionSource: "devEngines",
+						OnFail:        "error",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when package.json declares engines.pnpm", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"engines": {"pnpm": "^8.15.0"}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("requires a pnpm version satisfying the range", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "^8.15.0",
+						VersionSource: "engines",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when there is a pnpm-lock.yaml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+		})
+
+		it("requires a pnpm version compatible with the lockfile", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "8.*",
+						VersionSource: "pnpm-lock.yaml",
+					},
+				},
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+			}))
+		})
+
+		context("when BP_PNPM_LAUNCH is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_PNPM_LAUNCH", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_LAUNCH")).To(Succeed())
+			})
+
+			it("requires pnpm at launch as well", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build:  true,
+						Launch: true,
+					},
+				}))
+			})
+		})
+	})
+
+	context("when there is a pnpm-workspace.yaml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte("packages:\n  - 'apps/*'\n"), 0600)).To(Succeed())
+		})
+
+		it("requires pnpm during the build", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pnpm"},
+					},
+					Requires: []packit.BuildPlanRequirement{
+						{
+							Name: "pnpm",
+							Metadata: pnpm.BuildPlanMetadata{
+								Build: true,
+							},
+						},
+					},
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+								{Name: "pnpm-store"},
+							},
+							Requires: []packit.BuildPlanRequirement{
+								{
+									Name: "pnpm",
+									Metadata: pnpm.BuildPlanMetadata{
+										Build: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			}))
+		})
+	})
+
+	context("when BP_NODE_PROJECT_PATH points to an app within a pnpm workspace", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "apps", "api"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "apps", "api", "package.json"), []byte(`{
+				"engines": {"pnpm": ">=9"}
+			}`), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"packageManager": "pnpm@9.12.3",
+				"engines": {"pnpm": ">=8"}
+			}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte("packages:\n  - 'apps/*'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '9.0'\n"), 0600)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+		})
+
+		it("reads the app pins first and falls back to the workspace root", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "9.12.3",
+						VersionSource: "package.json",
+					},
+				},
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       ">=9",
+						VersionSource: "engines",
+					},
+				},
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "9.* || 10.*",
+						VersionSource: "pnpm-lock.yaml",
+					},
+				},
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+			}))
+		})
+	})
+
+	context("when BP_NODE_PROJECT_PATH points to an app outside of a pnpm workspace", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "app")).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "app"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "app", ".tool-versions"), []byte("pnpm 8.15.4\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '9.0'\n"), 0600)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+		})
+
+		it("only reads the files of the app", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "8.15.4",
+						VersionSource: ".tool-versions",
+					},
+				},
+			}))
+		})
+	})
+
+	context("when the buildpack.toml has a node-compatibility table", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[metadata]
+  [metadata.default_versions]
+    pnpm = "10.*"
+
+  [[metadata.dependencies]]
+    id = "pnpm"
+    version = "10.29.3"
+
+  [[metadata.dependencies]]
+    id = "pnpm"
+    version = "8.15.9"
+
+  [[metadata.node-compatibility]]
+    node = ">=18.12"
+    pnpm = ">=9"
+
+  [[metadata.node-compatibility]]
+    node = ">=16.14"
+    pnpm = "8.*"
+`), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+		})
+
+		it("requires a compatible version of node, with a plan without node as an alternative", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			pnpmRequirements := []packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "8.*",
+						VersionSource: "pnpm-lock.yaml",
+					},
+				},
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+			}
+
+			nodeRequirements := append(append([]packit.BuildPlanRequirement{}, pnpmRequirements...), packit.BuildPlanRequirement{
+				Name: "node",
+				Metadata: pnpm.BuildPlanMetadata{
+					Version:       ">=16.14",
+					VersionSource: "pnpm",
+					Build:         true,
+				},
+			})
+
+			Expect(result).To(Equal(packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pnpm"},
+					},
+					Requires: nodeRequirements,
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+							},
+							Requires: pnpmRequirements,
+						},
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+								{Name: "pnpm-store"},
+							},
+							Requires: nodeRequirements,
+						},
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+								{Name: "pnpm-store"},
+							},
+							Requires: pnpmRequirements,
+						},
+					},
+				},
+			}))
+		})
+
+		context("when no version is requested", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '42.0'\n"), 0600)).To(Succeed())
+			})
+
+			it("uses the node requirement of the default version", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+					Name: "node",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       ">=18.12",
+						VersionSource: "pnpm",
+						Build:         true,
+					},
+				}))
+			})
+		})
+
+		context("when pnpm is not required", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "pnpm-lock.yaml"))).To(Succeed())
+			})
+
+			it("does not require node", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(BeEmpty())
+				Expect(result.Plan.Or).To(Equal([]packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: "pnpm"},
+							{Name: "pnpm-store"},
+						},
+					},
+				}))
+			})
+		})
+	})
+
+	context("when BP_PNPM_PROVIDE_NODE is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_PNPM_PROVIDE_NODE", "true")).To(Succeed())
+			Expect(os.Setenv("BP_NODE_VERSION", "22.*")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), nil, 0600)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_PNPM_PROVIDE_NODE")).To(Succeed())
+			Expect(os.Unsetenv("BP_NODE_VERSION")).To(Succeed())
+		})
+
+		it("provides and requires node", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			requirements := []packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: "node",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: "node",
+					Metadata: pnpm.BuildPlanMetadata{
+						Version:       "22.*",
+						VersionSource: "BP_NODE_VERSION",
+						Build:         true,
+					},
+				},
+			}
+
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "pnpm"},
+					{Name: "node"},
+				},
+				Requires: requirements,
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: "pnpm"},
+							{Name: "node"},
+							{Name: "pnpm-store"},
+						},
+						Requires: requirements,
+					},
+				},
+			}))
+		})
+
+		context("when nothing requires pnpm", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "pnpm-workspace.yaml"))).To(Succeed())
+			})
+
+			it("does not provide node", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Plan).To(Equal(packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pnpm"},
+					},
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pnpm"},
+								{Name: "pnpm-store"},
+							},
+						},
+					},
+				}))
+			})
+		})
+
+		context("when the JS distribution is selected", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_PNPM_DISTRIBUTION", "js")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_DISTRIBUTION")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("BP_PNPM_PROVIDE_NODE requires the standalone distribution of pnpm")))
+			})
+		})
+	})
+
+	context("when BP_PNPM_DISTRIBUTION is js", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_PNPM_DISTRIBUTION", "js")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), nil, 0600)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_PNPM_DISTRIBUTION")).To(Succeed())
+		})
+
+		it("requires node without an alternative", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			requirements := []packit.BuildPlanRequirement{
+				{
+					Name: "pnpm",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: "node",
+					Metadata: pnpm.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+			}
+
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "pnpm"},
+				},
+				Requires: requirements,
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: "pnpm"},
+							{Name: "pnpm-store"},
+						},
+						Requires: requirements,
+					},
+				},
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the package.json cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`%%%`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+			})
+		})
+
+		context("when the volta configuration cannot be resolved", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+					"volta": {"extends": "../package.json"}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to resolve volta extends")))
+			})
+		})
+
+		context("when BP_NODE_PROJECT_PATH does not exist", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "missing")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("could not find project path")))
+				Expect(err).To(MatchError(os.ErrNotExist))
+			})
+		})
+
+		context("when BP_NODE_PROJECT_PATH points outside of the working directory", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "../other")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(`BP_NODE_PROJECT_PATH "../other" points outside of the working directory`))
+			})
+		})
+
+		context("when BP_PNPM_PROVIDE_NODE is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_PNPM_PROVIDE_NODE", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_PROVIDE_NODE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_PNPM_PROVIDE_NODE")))
+			})
+		})
+
+		context("when BP_PNPM_DISTRIBUTION is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_PNPM_DISTRIBUTION", "deno")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_DISTRIBUTION")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(`invalid BP_PNPM_DISTRIBUTION value "deno": must be one of "standalone" or "js"`))
+			})
+		})
+
+		context("when the buildpack.toml cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte("%%%"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), nil, 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+			})
+		})
+
+		context("when the mise.toml cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "mise.toml"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse mise.toml")))
+			})
+		})
+
+		context("when the .tool-versions cannot be read", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".tool-versions"), nil, 0000)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to open .tool-versions")))
+			})
+		})
+
+		context("when engines.pnpm is not a valid range", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+					"engines": {"pnpm": "not-a-range"}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring(`invalid pnpm version range "not-a-range"`)))
+			})
+		})
+
+		context("when BP_PNPM_STRICT is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), nil, 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), nil, 0600)).To(Succeed())
+				Expect(os.Setenv("BP_PNPM_STRICT", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_STRICT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_PNPM_STRICT")))
+			})
+		})
+
+		context("when BP_PNPM_LAUNCH is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), nil, 0600)).To(Succeed())
+				Expect(os.Setenv("BP_PNPM_LAUNCH", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_PNPM_LAUNCH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_PNPM_LAUNCH")))
+			})
+		})
+
+		context("when the pnpm-lock.yaml cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: [%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse pnpm-lock.yaml")))
+			})
+		})
+	})
 }
@@ -10,6 +10,10 @@ import (
 func TestUnitPnpm(t *testing.T) {
 	suite := spec.New("pnpm", spec.Report(report.Terminal{}), spec.Parallel())
 	suite("Build", testBuild, spec.Sequential())
-	suite("Detect", testDetect)
+	suite("Detect", testDetect, spec.Sequential())
+	suite("MiseTOMLParser", testMiseTOMLParser)
+	suite("PackageJSONParser", testPackageJSONParser)
+	suite("PnpmLockParser", testPnpmLockParser)
+	suite("ToolVersionsParser", testToolVersionsParser)
 	suite.Run(t)
 }
@@ -99,6 +99,15 @@ func testDefault(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(logs).To(ContainLines(
 				MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+				"  Resolving pnpm version",
+				"    Candidate version sources (in priority order):",
+				`      <unknown> -> ""`,
+				"",
+				MatchRegexp(`    Selected pnpm version \(using <unknown>\): \d+\.\d+\.\d+`),
+				"",
+				"  Configuring pnpm store",
+				fmt.Sprintf("    Creating pnpm store /layers/%s/pnpm-store", strings.ReplaceAll(settings.Buildpack.ID, "/", "_")),
+				"",
 				"  Executing build process",
 				MatchRegexp(`    Installing pnpm`),
 				MatchRegexp(`      Completed in ([0-9]*(\.[0-9]*)?[a-z]+)+`),
@@ -193,6 +202,15 @@ func testDefault(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(logs).To(ContainLines(
 				MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+				"  Resolving pnpm version",
+				"    Candidate version sources (in priority order):",
+				`      <unknown> -> ""`,
+				"",
+				MatchRegexp(`    Selected pnpm version \(using <unknown>\): \d+\.\d+\.\d+`),
+				"",
+				"  Configuring pnpm store",
+				fmt.Sprintf("    Creating pnpm store /layers/%s/pnpm-store", strings.ReplaceAll(settings.Buildpack.ID, "/", "_")),
+				"",
 				"  Executing build process",
 				MatchRegexp(`    Installing pnpm`),
 				MatchRegexp(`      Completed in ([0-9]*(\.[0-9]*)?[a-z]+)+`),
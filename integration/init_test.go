@@ -96,5 +96,6 @@ func TestIntegration(t *testing.T) {
 	suite("Default", testDefault)
 	suite("LayerReuse", testRebuildLayerReuse)
 	suite("Offline", testOffline)
+	suite("Standalone", testStandalone)
 	suite.Run(t)
 }
@@ -0,0 +1,70 @@
+package pnpm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/cargo"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// requestedPackageManagerIntegrity returns the hash that the packageManager
+// field of package.json pins the pnpm tarball to, e.g. "sha512.<hex>", if
+// any.
+func requestedPackageManagerIntegrity(entries []packit.BuildpackPlanEntry) string {
+	for _, entry := range entries {
+		if source, _ := entry.Metadata["version-source"].(string); source != "package.json" {
+			continue
+		}
+
+		integrity, _ := entry.Metadata["integrity"].(string)
+		return integrity
+	}
+
+	return ""
+}
+
+// verifyPackageManagerIntegrity compares the hash pinned by the
+// packageManager field with the checksum of the pnpm dependency that the
+// buildpack installs for the pinned version. The dependency manager verifies
+// the artifact it delivers against that checksum, so a match means that the
+// application runs the npm tarball it pinned. The hash describes the npm
+// tarball, so verification is skipped for the standalone executables and for
+// checksums that use another algorithm.
+func verifyPackageManagerIntegrity(dependency postal.Dependency, integrity string, logger scribe.Emitter) error {
+	algorithm, encoded, found := strings.Cut(integrity, ".")
+	if !found {
+		return fmt.Errorf("failed to parse packageManager integrity %q: expected <algorithm>.<hex>", integrity)
+	}
+
+	_, err := hex.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to parse packageManager integrity %q: %w", integrity, err)
+	}
+
+	if dependency.ID != PnpmJSDependency {
+		logger.Subprocess("The packageManager hash describes the npm tarball of pnpm %s, skipping its verification against the %s dependency", dependency.Version, dependency.ID)
+		logger.Break()
+		return nil
+	}
+
+	checksum := cargo.Checksum(dependency.Checksum)
+	if checksum.Algorithm() != algorithm {
+		logger.Subprocess("The npm tarball of pnpm %s has no %s checksum, skipping verification of the packageManager hash", dependency.Version, algorithm)
+		logger.Break()
+		return nil
+	}
+
+	if !checksum.Match(cargo.Checksum(fmt.Sprintf("%s:%s", algorithm, encoded))) {
+		return fmt.Errorf("packageManager pins pnpm %s to %s, which does not match the checksum %s of the npm tarball that the buildpack installs",
+			dependency.Version, integrity, dependency.Checksum)
+	}
+
+	logger.Subprocess("Verified the packageManager hash of pnpm %s against the checksum of its npm tarball", dependency.Version)
+	logger.Break()
+
+	return nil
+}
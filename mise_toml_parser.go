@@ -0,0 +1,58 @@
+package pnpm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+type MiseTOMLParser struct{}
+
+func NewMiseTOMLParser() MiseTOMLParser {
+	return MiseTOMLParser{}
+}
+
+// ParseVersion returns the pnpm version pinned in the [tools] table of the
+// mise.toml file at the given path. The tool may be declared as a version
+// string, a list of versions, of which the first is used, or a table with a
+// version key. It returns an empty string when the file does not exist or
+// does not pin pnpm.
+func (p MiseTOMLParser) ParseVersion(path string) (string, error) {
+	var config struct {
+		Tools map[string]interface{} `toml:"tools"`
+	}
+
+	_, err := toml.DecodeFile(path, &config)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	tool, ok := config.Tools[PnpmDependency]
+	if !ok {
+		return "", nil
+	}
+
+	switch tool := tool.(type) {
+	case string:
+		return releaseVersion(tool), nil
+	case []interface{}:
+		if len(tool) > 0 {
+			if version, ok := tool[0].(string); ok {
+				return releaseVersion(version), nil
+			}
+		}
+	case map[string]interface{}:
+		if version, ok := tool["version"].(string); ok {
+			return releaseVersion(version), nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to parse %s: unsupported pnpm tool declaration %v", filepath.Base(path), tool)
+}
@@ -0,0 +1,112 @@
+package pnpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pnpm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMiseTOMLParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser pnpm.MiseTOMLParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "mise.toml")
+
+		parser = pnpm.NewMiseTOMLParser()
+	})
+
+	context("ParseVersion", func() {
+		it("returns the pinned pnpm version", func() {
+			Expect(os.WriteFile(path, []byte(`
+[tools]
+node = "20"
+pnpm = "9.12.3"
+`), 0600)).To(Succeed())
+
+			version, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("9.12.3"))
+		})
+
+		context("when pnpm is pinned to a list of versions", func() {
+			it("returns the first version", func() {
+				Expect(os.WriteFile(path, []byte(`
+[tools]
+pnpm = ["9", "8.15.4"]
+`), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal("9"))
+			})
+		})
+
+		context("when pnpm is pinned with a table", func() {
+			it("returns the version key", func() {
+				Expect(os.WriteFile(path, []byte(`
+[tools]
+pnpm = { version = "10.1.0", os = ["linux"] }
+`), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal("10.1.0"))
+			})
+		})
+
+		context("when pnpm is not pinned", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte(`
+[tools]
+node = "20"
+`), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when the mise.toml file does not exist", func() {
+			it("returns an empty string", func() {
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the mise.toml file is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse mise.toml")))
+				})
+			})
+
+			context("when the pnpm declaration is not supported", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("[tools]\npnpm = 9\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError("failed to parse mise.toml: unsupported pnpm tool declaration 9"))
+				})
+			})
+		})
+	})
+}
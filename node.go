@@ -0,0 +1,337 @@
+package pnpm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/draft"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// nodeVersionSourcePriorities lists the version-sources of node buildpack
+// plan entries, from the highest to the lowest priority. Entries without a
+// version-source rank below all of them.
+var nodeVersionSourcePriorities = []interface{}{
+	"BP_NODE_VERSION",
+	"pnpm",
+}
+
+// nodeTarballPattern matches the names of the Node.js release tarballs, as
+// published on https://nodejs.org/download/release/ and downloaded by pnpm env.
+var nodeTarballPattern = regexp.MustCompile(`^node-v(\d+\.\d+\.\d+)-linux-(x64|arm64)\.tar\.gz$`)
+
+// nodeTarball is a Node.js release tarball vendored with the application.
+type nodeTarball struct {
+	Version string
+	Path    string
+}
+
+// resolveNode selects the vendored Node.js tarball to install when the build
+// plan routed node requirements to this buildpack, which only happens when it
+// provides node itself. The tarball is the highest version that satisfies
+// every requirement among those in the BP_PNPM_NODE_TARBALLS directory of the
+// application that match the architecture of the build. It returns nil when
+// node is not required.
+func resolveNode(context packit.BuildContext, clock chronos.Clock, logger scribe.Emitter) (*nodeTarball, error) {
+	var required bool
+	for _, entry := range context.Plan.Entries {
+		if entry.Name == NodeDependency {
+			required = true
+			break
+		}
+	}
+
+	if !required {
+		return nil, nil
+	}
+
+	logger.Process("Resolving Node.js version")
+
+	planner := draft.NewPlanner()
+	entry, sortedEntries := planner.Resolve(NodeDependency, context.Plan.Entries, nodeVersionSourcePriorities)
+	logger.Candidates(sortedEntries)
+
+	var constraints []VersionConstraint
+Entries:
+	for _, e := range sortedEntries {
+		version, ok := e.Metadata["version"].(string)
+		if !ok || version == "" || version == "default" {
+			continue
+		}
+
+		version, err := translateVersionRange(version)
+		if err != nil {
+			return nil, err
+		}
+
+		source, ok := e.Metadata["version-source"].(string)
+		if !ok {
+			source = "<unknown>"
+		}
+
+		constraint := VersionConstraint{Source: source, Constraint: version}
+		for _, c := range constraints {
+			if c == constraint {
+				continue Entries
+			}
+		}
+
+		constraints = append(constraints, constraint)
+	}
+
+	dir := nodeTarballsDir(context.WorkingDir)
+	tarballs, err := findNodeTarballs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *nodeTarball
+Tarballs:
+	for _, tarball := range tarballs {
+		version := semver.MustParse(tarball.Version)
+		for _, c := range constraints {
+			constraint, err := semver.NewConstraint(c.Constraint)
+			if err != nil {
+				return nil, err
+			}
+
+			if !constraint.Check(version) {
+				continue Tarballs
+			}
+		}
+
+		selected = &tarball
+		break
+	}
+
+	if selected == nil {
+		err := fmt.Errorf("no Node.js tarball for linux-%s in %s", nodeArch(), dir)
+		if len(tarballs) > 0 {
+			err = fmt.Errorf("none of the Node.js tarballs for linux-%s in %s match", nodeArch(), dir)
+		}
+
+		return nil, ConstraintConflictError{Dependency: NodeDependency, Constraints: constraints, Err: err}
+	}
+
+	logger.SelectedDependency(entry, postal.Dependency{Name: "Node.js", Version: selected.Version}, clock.Now())
+
+	return selected, nil
+}
+
+// installNode installs the given Node.js tarball into its own layer through
+// pnpm env, which links node, npm and npx into the PNPM_HOME that the layer
+// serves as. pnpm env downloads Node.js from a mirror of the Node.js releases,
+// so it is pointed to a mirror on the loopback interface that serves only the
+// vendored tarball, and the build never leaves the machine. The pnpm
+// executable is looked up in pnpmPath. It returns no layer when no tarball is
+// given.
+func installNode(
+	context packit.BuildContext,
+	tarball *nodeTarball,
+	pnpmExecutable Executable,
+	pnpmPath string,
+	sbomGenerator SBOMGenerator,
+	clock chronos.Clock,
+	logger scribe.Emitter,
+) ([]packit.Layer, error) {
+	if tarball == nil {
+		return nil, nil
+	}
+
+	checksum, err := fs.NewChecksumCalculator().Sum(tarball.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	dependency := postal.Dependency{
+		ID:       NodeDependency,
+		Name:     "Node.js",
+		Version:  tarball.Version,
+		Checksum: fmt.Sprintf("sha256:%s", checksum),
+		Source:   tarball.Path,
+		URI:      tarball.Path,
+	}
+
+	nodeLayer, err := context.Layers.Get(NodeLayerName)
+	if err != nil {
+		return nil, err
+	}
+
+	launch, build := draft.NewPlanner().MergeLayerTypes(NodeDependency, context.Plan.Entries)
+
+	cachedSHA, ok := nodeLayer.Metadata[DependencyCacheKey].(string)
+	if ok && postal.Checksum(dependency.Checksum).MatchString(cachedSHA) {
+		logger.Process("Reusing cached layer %s", nodeLayer.Path)
+		logger.Break()
+
+		nodeLayer.Launch, nodeLayer.Build, nodeLayer.Cache = launch, build, build
+
+		return []packit.Layer{nodeLayer}, nil
+	}
+
+	logger.Process("Executing build process")
+
+	nodeLayer, err = nodeLayer.Reset()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLayer.Launch, nodeLayer.Build, nodeLayer.Cache = launch, build, build
+
+	logger.Subprocess("Installing Node.js %s through pnpm env", tarball.Version)
+
+	mirror, err := serveNodeMirror(*tarball, checksum)
+	if err != nil {
+		return nil, err
+	}
+	defer mirror.Close()
+
+	buffer := bytes.NewBuffer(nil)
+	args := []string{"env", "use", "--global", tarball.Version}
+	duration, err := clock.Measure(func() error {
+		return pnpmExecutable.Execute(pexec.Execution{
+			Args: args,
+			Dir:  nodeLayer.Path,
+			Env: append(os.Environ(),
+				fmt.Sprintf("PATH=%s", strings.Join([]string{pnpmPath, nodeLayer.Path, os.Getenv("PATH")}, string(os.PathListSeparator))),
+				fmt.Sprintf("PNPM_HOME=%s", nodeLayer.Path),
+				fmt.Sprintf("npm_config_node_mirror:release=http://%s/", mirror.Addr),
+			),
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute pnpm %s: %w\n%s", strings.Join(args, " "), err, buffer.String())
+	}
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	nodeLayer, err = attachSBOM(nodeLayer, "Node.js", dependency, context.BuildpackInfo.SBOMFormats, sbomGenerator, clock, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLayer.Metadata = map[string]interface{}{
+		DependencyCacheKey: dependency.Checksum,
+	}
+
+	nodeLayer.SharedEnv.Prepend("PATH", nodeLayer.Path, string(os.PathListSeparator))
+
+	return []packit.Layer{nodeLayer}, nil
+}
+
+// nodeMirror is a mirror of the Node.js releases that serves a single
+// tarball, along with the index.json and SHASUMS256.txt that pnpm env reads.
+type nodeMirror struct {
+	Addr   string
+	server *http.Server
+}
+
+func (m nodeMirror) Close() error {
+	return m.server.Close()
+}
+
+func serveNodeMirror(tarball nodeTarball, checksum string) (nodeMirror, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nodeMirror{}, fmt.Errorf("failed to serve the Node.js mirror: %w", err)
+	}
+
+	name := filepath.Base(tarball.Path)
+	index, err := json.Marshal([]map[string]interface{}{
+		{
+			"version": fmt.Sprintf("v%s", tarball.Version),
+			"lts":     false,
+			"files":   []string{fmt.Sprintf("linux-%s", nodeArch())},
+		},
+	})
+	if err != nil {
+		return nodeMirror{}, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(index)
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/SHASUMS256.txt", tarball.Version), func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s  %s\n", checksum, name)
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/%s", tarball.Version, name), func(w http.ResponseWriter, req *http.Request) {
+		http.ServeFile(w, req, tarball.Path)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return nodeMirror{Addr: listener.Addr().String(), server: server}, nil
+}
+
+// findNodeTarballs returns the Node.js tarballs of the given directory for the
+// architecture of the build, from the highest to the lowest version.
+func findNodeTarballs(dir string) ([]nodeTarball, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the Node.js tarballs: %w", err)
+	}
+
+	var tarballs []nodeTarball
+	for _, entry := range entries {
+		matches := nodeTarballPattern.FindStringSubmatch(entry.Name())
+		if matches == nil || matches[2] != nodeArch() {
+			continue
+		}
+
+		tarballs = append(tarballs, nodeTarball{Version: matches[1], Path: filepath.Join(dir, entry.Name())})
+	}
+
+	slices.SortFunc(tarballs, func(a, b nodeTarball) int {
+		return semver.MustParse(b.Version).Compare(semver.MustParse(a.Version))
+	})
+
+	return tarballs, nil
+}
+
+// nodeTarballsDir returns the directory of the vendored Node.js tarballs,
+// given relative to the application by BP_PNPM_NODE_TARBALLS.
+func nodeTarballsDir(workingDir string) string {
+	dir, ok := os.LookupEnv("BP_PNPM_NODE_TARBALLS")
+	if !ok || dir == "" {
+		dir = filepath.Join("vendor", "node")
+	}
+
+	return filepath.Join(workingDir, dir)
+}
+
+// nodeArch returns the architecture of the build as it appears in the names
+// of the Node.js release tarballs.
+func nodeArch() string {
+	if runtime.GOARCH == "amd64" {
+		return "x64"
+	}
+
+	return runtime.GOARCH
+}
@@ -0,0 +1,114 @@
+package pnpm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+)
+
+// NodeCompatibility is an entry of the node-compatibility table in the
+// buildpack.toml metadata. It gives the Node.js versions that the pnpm
+// versions matching the Pnpm constraint are able to run on.
+type NodeCompatibility struct {
+	Pnpm string `toml:"pnpm"`
+	Node string `toml:"node"`
+}
+
+type buildpackTOML struct {
+	Metadata struct {
+		DefaultVersions map[string]string `toml:"default_versions"`
+		Dependencies    []struct {
+			ID      string `toml:"id"`
+			Version string `toml:"version"`
+		} `toml:"dependencies"`
+		NodeCompatibility []NodeCompatibility `toml:"node-compatibility"`
+	} `toml:"metadata"`
+}
+
+func parseBuildpackTOML(path string) (buildpackTOML, error) {
+	var config buildpackTOML
+	_, err := toml.DecodeFile(path, &config)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return buildpackTOML{}, fmt.Errorf("failed to parse buildpack.toml: %w", err)
+	}
+
+	return config, nil
+}
+
+// hasDependency reports whether the dependencies table lists any version of
+// the dependency with the given id.
+func (b buildpackTOML) hasDependency(id string) bool {
+	for _, dependency := range b.Metadata.Dependencies {
+		if dependency.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeConstraint returns the constraint on Node.js versions that the given
+// pnpm version runs on, or an empty string when the table has no entry for
+// it.
+func (b buildpackTOML) nodeConstraint(pnpmVersion string) (string, error) {
+	version, err := semver.NewVersion(pnpmVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pnpm version %q: %w", pnpmVersion, err)
+	}
+
+	for _, entry := range b.Metadata.NodeCompatibility {
+		constraint, err := semver.NewConstraint(entry.Pnpm)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse node-compatibility constraint %q: %w", entry.Pnpm, err)
+		}
+
+		if constraint.Check(version) {
+			return entry.Node, nil
+		}
+	}
+
+	return "", nil
+}
+
+// highestPnpmVersion returns the highest pnpm version listed in the
+// dependencies that satisfies the given constraint, or the default version
+// constraint when it is empty. It returns an empty string when none does.
+func (b buildpackTOML) highestPnpmVersion(versionConstraint string) (string, error) {
+	if versionConstraint == "" {
+		versionConstraint = b.Metadata.DefaultVersions[PnpmDependency]
+	}
+
+	if versionConstraint == "" {
+		versionConstraint = "*"
+	}
+
+	constraint, err := semver.NewConstraint(versionConstraint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pnpm version constraint %q: %w", versionConstraint, err)
+	}
+
+	var highest *semver.Version
+	for _, dependency := range b.Metadata.Dependencies {
+		if dependency.ID != PnpmDependency {
+			continue
+		}
+
+		version, err := semver.NewVersion(dependency.Version)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse pnpm version %q: %w", dependency.Version, err)
+		}
+
+		if constraint.Check(version) && (highest == nil || version.GreaterThan(highest)) {
+			highest = version
+		}
+	}
+
+	if highest == nil {
+		return "", nil
+	}
+
+	return highest.String(), nil
+}
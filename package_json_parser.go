@@ -0,0 +1,186 @@
+package pnpm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageManager describes the value of the "packageManager" field of a
+// package.json file, e.g. "pnpm@9.12.3+sha512.abc".
+type PackageManager struct {
+	Name      string
+	Version   string
+	Integrity string
+}
+
+// DevEngine describes an entry of the "devEngines.packageManager" field of a
+// package.json file.
+type DevEngine struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	OnFail  string `json:"onFail"`
+}
+
+type packageJSON struct {
+	PackageManager string `json:"packageManager"`
+	Engines        struct {
+		Pnpm string `json:"pnpm"`
+	} `json:"engines"`
+	DevEngines struct {
+		PackageManager json.RawMessage `json:"packageManager"`
+	} `json:"devEngines"`
+	Volta struct {
+		Pnpm    string `json:"pnpm"`
+		Extends string `json:"extends"`
+	} `json:"volta"`
+}
+
+type PackageJSONParser struct{}
+
+func NewPackageJSONParser() PackageJSONParser {
+	return PackageJSONParser{}
+}
+
+// ParsePackageManager reads the "packageManager" field of the package.json
+// at the given path. It returns an empty PackageManager when the file does not
+// exist or the field is not set.
+func (p PackageJSONParser) ParsePackageManager(path string) (PackageManager, error) {
+	pkg, err := p.parse(path)
+	if err != nil {
+		return PackageManager{}, err
+	}
+
+	if pkg.PackageManager == "" {
+		return PackageManager{}, nil
+	}
+
+	name, version, found := strings.Cut(pkg.PackageManager, "@")
+	if !found || name == "" || version == "" {
+		return PackageManager{}, fmt.Errorf("failed to parse packageManager field %q: expected <name>@<version>", pkg.PackageManager)
+	}
+
+	version, integrity, _ := strings.Cut(version, "+")
+
+	return PackageManager{
+		Name:      name,
+		Version:   version,
+		Integrity: integrity,
+	}, nil
+}
+
+// ParseEngines returns the value of the "engines.pnpm" field of the
+// package.json at the given path.
+func (p PackageJSONParser) ParseEngines(path string) (string, error) {
+	pkg, err := p.parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	return pkg.Engines.Pnpm, nil
+}
+
+// ParseDevEngines returns the "devEngines.packageManager" entry of the
+// package.json at the given path. The field may either hold a single object or
+// a list of alternatives, in which case the pnpm entry is preferred. OnFail
+// defaults to "error" when it is not set.
+func (p PackageJSONParser) ParseDevEngines(path string) (DevEngine, error) {
+	pkg, err := p.parse(path)
+	if err != nil {
+		return DevEngine{}, err
+	}
+
+	raw := bytes.TrimSpace(pkg.DevEngines.PackageManager)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return DevEngine{}, nil
+	}
+
+	var devEngines []DevEngine
+	if raw[0] == '[' {
+		err = json.Unmarshal(raw, &devEngines)
+	} else {
+		devEngines = make([]DevEngine, 1)
+		err = json.Unmarshal(raw, &devEngines[0])
+	}
+	if err != nil {
+		return DevEngine{}, fmt.Errorf("failed to parse devEngines.packageManager field: %w", err)
+	}
+
+	if len(devEngines) == 0 {
+		return DevEngine{}, nil
+	}
+
+	devEngine := devEngines[0]
+	for _, d := range devEngines {
+		if d.Name == PnpmDependency {
+			devEngine = d
+			break
+		}
+	}
+
+	if devEngine.OnFail == "" {
+		devEngine.OnFail = "error"
+	}
+
+	return devEngine, nil
+}
+
+// ParseVolta returns the value of the "volta.pnpm" field of the package.json
+// at the given path. When the field is not set, the chain of package.json
+// files referenced by "volta.extends" is followed until one of them pins
+// pnpm. Every file of the chain must be located within the given workspace
+// root.
+func (p PackageJSONParser) ParseVolta(path, root string) (string, error) {
+	visited := map[string]bool{}
+	for {
+		if visited[path] {
+			return "", fmt.Errorf("failed to resolve volta extends: %s is part of a cycle", path)
+		}
+		visited[path] = true
+
+		pkg, err := p.parse(path)
+		if err != nil {
+			return "", err
+		}
+
+		if pkg.Volta.Pnpm != "" || pkg.Volta.Extends == "" {
+			return pkg.Volta.Pnpm, nil
+		}
+
+		extends := filepath.Join(filepath.Dir(path), pkg.Volta.Extends)
+		if !isWithin(root, extends) {
+			return "", fmt.Errorf("failed to resolve volta extends: %q points outside of the workspace", pkg.Volta.Extends)
+		}
+
+		_, err = os.Stat(extends)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve volta extends: %w", err)
+		}
+
+		path = extends
+	}
+}
+
+func (p PackageJSONParser) parse(path string) (packageJSON, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return packageJSON{}, nil
+		}
+
+		return packageJSON{}, fmt.Errorf("failed to open package.json: %w", err)
+	}
+	defer file.Close()
+
+	var pkg packageJSON
+	err = json.NewDecoder(file).Decode(&pkg)
+	if err != nil {
+		return packageJSON{}, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	return pkg, nil
+}
@@ -0,0 +1,298 @@
+package pnpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pnpm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackageJSONParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser pnpm.PackageJSONParser
+	)
+
+	it.Before(func() {
+		workingDir := t.TempDir()
+		path = filepath.Join(workingDir, "package.json")
+
+		parser = pnpm.NewPackageJSONParser()
+	})
+
+	context("ParsePackageManager", func() {
+		it("returns the package manager name and version", func() {
+			Expect(os.WriteFile(path, []byte(`{"packageManager": "pnpm@9.12.3"}`), 0600)).To(Succeed())
+
+			packageManager, err := parser.ParsePackageManager(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packageManager).To(Equal(pnpm.PackageManager{
+				Name:    "pnpm",
+				Version: "9.12.3",
+			}))
+		})
+
+		context("when the version carries a corepack hash suffix", func() {
+			it("strips the suffix from the version", func() {
+				Expect(os.WriteFile(path, []byte(`{"packageManager": "pnpm@9.12.3+sha512.abc123"}`), 0600)).To(Succeed())
+
+				packageManager, err := parser.ParsePackageManager(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(packageManager).To(Equal(pnpm.PackageManager{
+					Name:      "pnpm",
+					Version:   "9.12.3",
+					Integrity: "sha512.abc123",
+				}))
+			})
+		})
+
+		context("when the packageManager field is not set", func() {
+			it("returns an empty package manager", func() {
+				Expect(os.WriteFile(path, []byte(`{"name": "some-app"}`), 0600)).To(Succeed())
+
+				packageManager, err := parser.ParsePackageManager(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(packageManager).To(Equal(pnpm.PackageManager{}))
+			})
+		})
+
+		context("when the package.json file does not exist", func() {
+			it("returns an empty package manager", func() {
+				packageManager, err := parser.ParsePackageManager(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(packageManager).To(Equal(pnpm.PackageManager{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, nil, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParsePackageManager(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to open package.json")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+
+			context("when the package.json file is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParsePackageManager(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+				})
+			})
+
+			context("when the packageManager field has no version", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`{"packageManager": "pnpm"}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParsePackageManager(path)
+					Expect(err).To(MatchError(`failed to parse packageManager field "pnpm": expected <name>@<version>`))
+				})
+			})
+		})
+	})
+
+	context("ParseEngines", func() {
+		it("returns the engines.pnpm range", func() {
+			Expect(os.WriteFile(path, []byte(`{"engines": {"node": ">=18", "pnpm": ">=8.15 <10"}}`), 0600)).To(Succeed())
+
+			engine, err := parser.ParseEngines(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(engine).To(Equal(">=8.15 <10"))
+		})
+
+		context("when engines.pnpm is not set", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte(`{"engines": {"node": ">=18"}}`), 0600)).To(Succeed())
+
+				engine, err := parser.ParseEngines(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(engine).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json file is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseEngines(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+				})
+			})
+		})
+	})
+
+	context("ParseDevEngines", func() {
+		it("returns the devEngines.packageManager entry", func() {
+			Expect(os.WriteFile(path, []byte(`{
+				"devEngines": {
+					"packageManager": {"name": "pnpm", "version": "^9.1.0", "onFail": "warn"}
+				}
+			}`), 0600)).To(Succeed())
+
+			devEngine, err := parser.ParseDevEngines(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(devEngine).To(Equal(pnpm.DevEngine{
+				Name:    "pnpm",
+				Version: "^9.1.0",
+				OnFail:  "warn",
+			}))
+		})
+
+		context("when onFail is not set", func() {
+			it("defaults to error", func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"devEngines": {
+						"packageManager": {"name": "pnpm", "version": "^9.1.0"}
+					}
+				}`), 0600)).To(Succeed())
+
+				devEngine, err := parser.ParseDevEngines(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(devEngine.OnFail).To(Equal("error"))
+			})
+		})
+
+		context("when devEngines.packageManager is a list", func() {
+			it("prefers the pnpm entry", func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"devEngines": {
+						"packageManager": [
+							{"name": "yarn", "version": "4.x"},
+							{"name": "pnpm", "version": "10.x", "onFail": "ignore"}
+						]
+					}
+				}`), 0600)).To(Succeed())
+
+				devEngine, err := parser.ParseDevEngines(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(devEngine).To(Equal(pnpm.DevEngine{
+					Name:    "pnpm",
+					Version: "10.x",
+					OnFail:  "ignore",
+				}))
+			})
+		})
+
+		context("when devEngines.packageManager is not set", func() {
+			it("returns an empty entry", func() {
+				Expect(os.WriteFile(path, []byte(`{"devEngines": {}}`), 0600)).To(Succeed())
+
+				devEngine, err := parser.ParseDevEngines(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(devEngine).To(Equal(pnpm.DevEngine{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when devEngines.packageManager is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`{"devEngines": {"packageManager": "pnpm"}}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseDevEngines(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse devEngines.packageManager field")))
+				})
+			})
+		})
+	})
+
+	context("ParseVolta", func() {
+		var root string
+
+		it.Before(func() {
+			root = filepath.Dir(path)
+		})
+
+		it("returns the volta.pnpm version", func() {
+			Expect(os.WriteFile(path, []byte(`{"volta": {"node": "20.11.0", "pnpm": "8.15.4"}}`), 0600)).To(Succeed())
+
+			version, err := parser.ParseVolta(path, root)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("8.15.4"))
+		})
+
+		context("when the volta configuration extends another package.json", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(root, "apps", "api"), os.ModePerm)).To(Succeed())
+				path = filepath.Join(root, "apps", "api", "package.json")
+
+				Expect(os.WriteFile(path, []byte(`{"volta": {"extends": "../package.json"}}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(root, "apps", "package.json"), []byte(`{"volta": {"node": "20.11.0", "extends": "../package.json"}}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(root, "package.json"), []byte(`{"volta": {"pnpm": "8.15.4"}}`), 0600)).To(Succeed())
+			})
+
+			it("follows the chain until pnpm is pinned", func() {
+				version, err := parser.ParseVolta(path, root)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal("8.15.4"))
+			})
+		})
+
+		context("when volta is not configured", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte(`{}`), 0600)).To(Succeed())
+
+				version, err := parser.ParseVolta(path, root)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when volta.extends points outside of the workspace", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`{"volta": {"extends": "../package.json"}}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVolta(path, root)
+					Expect(err).To(MatchError(`failed to resolve volta extends: "../package.json" points outside of the workspace`))
+				})
+			})
+
+			context("when volta.extends points to a missing file", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`{"volta": {"extends": "./base.json"}}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVolta(path, root)
+					Expect(err).To(MatchError(ContainSubstring("failed to resolve volta extends")))
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+
+			context("when volta.extends forms a cycle", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte(`{"volta": {"extends": "./base.json"}}`), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(root, "base.json"), []byte(`{"volta": {"extends": "./package.json"}}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVolta(path, root)
+					Expect(err).To(MatchError(ContainSubstring("is part of a cycle")))
+				})
+			})
+		})
+	})
+}
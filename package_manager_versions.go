@@ -0,0 +1,81 @@
+package pnpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// requestedPackageManagerVersion returns the pnpm version pinned by the
+// packageManager field of package.json, if any.
+func requestedPackageManagerVersion(entries []packit.BuildpackPlanEntry) string {
+	for _, entry := range entries {
+		if source, _ := entry.Metadata["version-source"].(string); source != "package.json" {
+			continue
+		}
+
+		version, _ := entry.Metadata["version"].(string)
+		return version
+	}
+
+	return ""
+}
+
+// switchesVersions reports whether running pnpm in an application that pins
+// the requested version through its packageManager field makes it switch to
+// that version at runtime. corepack always does so, while pnpm itself only
+// does so as of version 9.7.0.
+func switchesVersions(installMode, installedVersion, requestedVersion string) bool {
+	if requestedVersion == "" || requestedVersion == installedVersion {
+		return false
+	}
+
+	if installMode == InstallModeCorepack {
+		return true
+	}
+
+	version, err := semver.NewVersion(installedVersion)
+	if err != nil {
+		return false
+	}
+
+	return !version.LessThan(semver.MustParse("9.7.0"))
+}
+
+// seedPackageManagerVersion installs the given pnpm version where the pnpm
+// or corepack of the layer looks for the versions it switches to, so that
+// switching works without network access.
+func seedPackageManagerVersion(dependencyManager DependencyManager, dependency postal.Dependency, cnbPath, layerPath, platformPath, installMode, distribution string) error {
+	if installMode == InstallModeCorepack {
+		return seedCorepackCache(dependencyManager, dependency, cnbPath, filepath.Join(layerPath, "corepack"), platformPath)
+	}
+
+	// pnpm keeps the versions it switches to under the directory of the
+	// package it runs from, which is @pnpm/exe for the standalone executable.
+	toolsPackage, target := "@pnpm+exe", filepath.Join("..", "pnpm")
+	if distribution == DistributionJS {
+		toolsPackage, target = "pnpm", "pnpm.cjs"
+	}
+
+	toolDir := filepath.Join(layerPath, "pnpm-home", ".tools", toolsPackage, dependency.Version)
+	err := os.MkdirAll(filepath.Join(toolDir, "bin"), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create pnpm tools directory: %w", err)
+	}
+
+	err = dependencyManager.Deliver(dependency, cnbPath, toolDir, platformPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink(target, filepath.Join(toolDir, "bin", "pnpm"))
+	if err != nil {
+		return fmt.Errorf("failed to link pnpm %s: %w", dependency.Version, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package pnpm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockfileVersions maps a pnpm-lock.yaml lockfileVersion to a constraint on
+// the pnpm versions that are able to read it without rewriting it.
+var lockfileVersions = map[string]string{
+	"5.3": "6.*",
+	"5.4": "7.*",
+	"6.0": "8.*",
+	"6.1": "8.*",
+	"9.0": "9.* || 10.*",
+}
+
+type PnpmLockParser struct{}
+
+func NewPnpmLockParser() PnpmLockParser {
+	return PnpmLockParser{}
+}
+
+// ParseVersion reads the lockfileVersion of the pnpm-lock.yaml at the given
+// path and returns the constraint of pnpm versions compatible with it. It
+// returns an empty string when the file does not exist or the lockfile
+// version is unknown.
+func (p PnpmLockParser) ParseVersion(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to open pnpm-lock.yaml: %w", err)
+	}
+	defer file.Close()
+
+	var lockfile struct {
+		LockfileVersion string `yaml:"lockfileVersion"`
+	}
+	err = yaml.NewDecoder(file).Decode(&lockfile)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to parse pnpm-lock.yaml: %w", err)
+	}
+
+	return lockfileVersions[lockfile.LockfileVersion], nil
+}
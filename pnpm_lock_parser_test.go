@@ -0,0 +1,98 @@
+package pnpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pnpm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPnpmLockParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser pnpm.PnpmLockParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+
+		parser = pnpm.NewPnpmLockParser()
+	})
+
+	context("ParseVersion", func() {
+		for lockfileVersion, constraint := range map[string]string{
+			"5.4":   "7.*",
+			"'6.0'": "8.*",
+			"'9.0'": "9.* || 10.*",
+		} {
+			lockfileVersion, constraint := lockfileVersion, constraint
+
+			it("maps lockfileVersion "+lockfileVersion+" to a pnpm constraint", func() {
+				Expect(os.WriteFile(path, []byte("lockfileVersion: "+lockfileVersion+"\n"), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal(constraint))
+			})
+		}
+
+		context("when the lockfileVersion is unknown", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte("lockfileVersion: '42.0'\n"), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when the pnpm-lock.yaml file is empty", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, nil, 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when the pnpm-lock.yaml file does not exist", func() {
+			it("returns an empty string", func() {
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the pnpm-lock.yaml file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, nil, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to open pnpm-lock.yaml")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+
+			context("when the pnpm-lock.yaml file is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("lockfileVersion: [%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse pnpm-lock.yaml")))
+				})
+			})
+		})
+	})
+}
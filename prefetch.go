@@ -0,0 +1,98 @@
+package pnpm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// prefetchStore runs pnpm fetch against the pnpm-lock.yaml of the application
+// so that every package it lists is in the store before a later buildpack
+// installs them, which can then happen with --offline. The fetch is skipped
+// when the lockfile has not changed since the store was last filled. The
+// given environment is the one pnpm runs with.
+func prefetchStore(
+	context packit.BuildContext,
+	pnpmExecutable Executable,
+	storeLayer packit.Layer,
+	env []string,
+	clock chronos.Clock,
+	logger scribe.Emitter,
+) (packit.Layer, error) {
+	projectPath, err := findProjectPath(context.WorkingDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	workspaceRoot, err := findWorkspaceRoot(projectPath, context.WorkingDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	lockfilePath := filepath.Join(workspaceRoot, "pnpm-lock.yaml")
+	exists, err := fs.Exists(lockfilePath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if !exists {
+		logger.Process("Skipping pnpm fetch: no pnpm-lock.yaml found")
+		logger.Break()
+		return storeLayer, nil
+	}
+
+	lockfileSHA, err := fs.NewChecksumCalculator().Sum(lockfilePath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if cachedSHA, ok := storeLayer.Metadata[LockfileCacheKey].(string); ok && cachedSHA == lockfileSHA {
+		logger.Process("Skipping pnpm fetch: pnpm-lock.yaml is unchanged since the last fetch")
+		logger.Break()
+		return storeLayer, nil
+	}
+
+	logger.Process("Fetching the packages of pnpm-lock.yaml into the pnpm store")
+
+	buffer := bytes.NewBuffer(nil)
+	args := []string{"fetch"}
+	duration, err := clock.Measure(func() error {
+		return pnpmExecutable.Execute(pexec.Execution{
+			Args:   args,
+			Dir:    workspaceRoot,
+			Env:    env,
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+	})
+	if err != nil {
+		return packit.Layer{}, fmt.Errorf("failed to execute pnpm %s: %w\n%s", strings.Join(args, " "), err, buffer.String())
+	}
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	storeLayer.Metadata[LockfileCacheKey] = lockfileSHA
+
+	return storeLayer, nil
+}
+
+func checkPrefetchEnabled() (bool, error) {
+	if prefetchStr, ok := os.LookupEnv("BP_PNPM_PREFETCH"); ok {
+		prefetch, err := strconv.ParseBool(prefetchStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_PNPM_PREFETCH value %s: %w", prefetchStr, err)
+		}
+		return prefetch, nil
+	}
+	return false, nil
+}
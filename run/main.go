@@ -8,9 +8,11 @@ import (
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/cargo"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/postal"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 type Generator struct{}
@@ -24,10 +26,14 @@ func main() {
 	logEmitter := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 
 	packit.Run(
-		pnpm.Detect(),
+		pnpm.Detect(logEmitter),
 		pnpm.Build(
 			dependencyManager,
 			Generator{},
+			servicebindings.NewResolver(),
+			pexec.NewExecutable("node"),
+			pexec.NewExecutable("corepack"),
+			pexec.NewExecutable("pnpm"),
 			chronos.DefaultClock,
 			logEmitter,
 		),
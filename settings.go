@@ -0,0 +1,194 @@
+package pnpm
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	configSettingPrefix = "BP_PNPM_CONFIG_"
+	npmConfigPrefix     = "npm_config_"
+)
+
+// secretSetting matches the names of the settings whose values are masked in
+// the build output.
+var secretSetting = regexp.MustCompile(`(?i)(auth|token|password|secret|cert|key$)`)
+
+// configSettings translates BP_PNPM_REGISTRY and the BP_PNPM_CONFIG_<KEY>
+// variables into npm_config_<key> settings, which pnpm reads like the
+// corresponding entries of an rc file. A variable applies during the build and
+// at launch, unless a _BUILD or _LAUNCH suffix limits it to one of them, in
+// which case it takes precedence over the variable without suffix for that
+// phase. BP_PNPM_REGISTRY is a shorthand for BP_PNPM_CONFIG_REGISTRY, which
+// takes precedence over it.
+func configSettings() (build, launch map[string]string, err error) {
+	type setting struct {
+		precedence int
+		name       string
+		value      string
+		build      bool
+		launch     bool
+	}
+
+	var settings []setting
+	if registry, ok := os.LookupEnv("BP_PNPM_REGISTRY"); ok {
+		settings = append(settings, setting{name: npmConfigPrefix + "registry", value: registry, build: true, launch: true})
+	}
+
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		key, ok := strings.CutPrefix(name, configSettingPrefix)
+		if !ok {
+			continue
+		}
+
+		s := setting{precedence: 1, value: value, build: true, launch: true}
+		if k, ok := strings.CutSuffix(key, "_BUILD"); ok {
+			key, s.precedence, s.launch = k, 2, false
+		} else if k, ok := strings.CutSuffix(key, "_LAUNCH"); ok {
+			key, s.precedence, s.build = k, 2, false
+		}
+
+		if key == "" {
+			return nil, nil, fmt.Errorf("invalid environment variable %s: must name a pnpm setting, e.g. %sNODE_LINKER", name, configSettingPrefix)
+		}
+
+		s.name = npmConfigPrefix + strings.ToLower(key)
+		settings = append(settings, s)
+	}
+
+	slices.SortStableFunc(settings, func(a, b setting) int {
+		return a.precedence - b.precedence
+	})
+
+	build, launch = map[string]string{}, map[string]string{}
+	for _, s := range settings {
+		if s.build {
+			build[s.name] = s.value
+		}
+
+		if s.launch {
+			launch[s.name] = s.value
+		}
+	}
+
+	return build, launch, nil
+}
+
+// logConfigSettings lists the settings translated from the environment along
+// with the phases they apply to. Secrets, whether a setting holding one or
+// the password of a URL, are masked.
+func logConfigSettings(build, launch map[string]string, logger scribe.Emitter) {
+	names := slices.Sorted(maps.Keys(build))
+	for name := range launch {
+		if _, ok := build[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	if len(names) == 0 {
+		return
+	}
+
+	logger.Process("Applying pnpm settings from the environment")
+	for _, name := range names {
+		buildValue, inBuild := build[name]
+		launchValue, inLaunch := launch[name]
+
+		if inBuild && inLaunch && buildValue == launchValue {
+			logger.Subprocess("%s=%s (build and launch)", name, maskSetting(name, buildValue))
+			continue
+		}
+
+		if inBuild {
+			logger.Subprocess("%s=%s (build)", name, maskSetting(name, buildValue))
+		}
+
+		if inLaunch {
+			logger.Subprocess("%s=%s (launch)", name, maskSetting(name, launchValue))
+		}
+	}
+	logger.Break()
+}
+
+func maskSetting(name, value string) string {
+	if value == "" {
+		return value
+	}
+
+	// The mask is the one that url.URL.Redacted puts in place of a password.
+	if secretSetting.MatchString(strings.TrimPrefix(name, npmConfigPrefix)) {
+		return "xxxxx"
+	}
+
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+
+	return value
+}
+
+// setLaunchSettings replaces the npm_config_ settings of the launch
+// environment of the layer, including those that a reused layer still holds
+// from a previous build. Launch settings only apply when the layer is
+// available at launch, and the user info of their URLs is removed so that
+// registry credentials never reach the launch image. Both are reported with a
+// warning.
+func setLaunchSettings(layer packit.Layer, settings map[string]string, logger scribe.Emitter) error {
+	for key := range layer.LaunchEnv {
+		if !strings.HasPrefix(key, npmConfigPrefix) {
+			continue
+		}
+
+		delete(layer.LaunchEnv, key)
+		err := os.Remove(filepath.Join(layer.Path, "env.launch", key))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove launch setting %s: %w", key, err)
+		}
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	names := slices.Sorted(maps.Keys(settings))
+	if !layer.Launch {
+		logger.Process("WARNING: pnpm is not available at launch, so these settings do not apply there: %s", strings.Join(names, ", "))
+		logger.Subprocess("Set BP_PNPM_LAUNCH=true, or have a later buildpack require pnpm at launch")
+		logger.Break()
+
+		return nil
+	}
+
+	var scrubbed []string
+	for _, name := range names {
+		value := settings[name]
+		if credentialURL.MatchString(value) {
+			value = credentialURL.ReplaceAllString(value, "$1")
+			scrubbed = append(scrubbed, name)
+		}
+
+		layer.LaunchEnv.Override(name, value)
+	}
+
+	if len(scrubbed) > 0 {
+		logger.Process("WARNING: refusing to make registry credentials available at launch")
+		for _, name := range scrubbed {
+			logger.Subprocess("Removed the user info of the URL in %s from the launch settings", name)
+		}
+		logger.Subprocess("Provide registry credentials through npmrc or pnpmrc service bindings, which only reach the build")
+		logger.Break()
+	}
+
+	return nil
+}
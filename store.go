@@ -0,0 +1,263 @@
+package pnpm
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// installStore contributes the layer that keeps the content-addressable store
+// of pnpm between builds, so that packages are only downloaded once. The
+// layer never ends up in the image. pnpm keeps each store version in a
+// directory of its own, but a store written by another major version of pnpm
+// is reset rather than left to grow with unused packages.
+func installStore(context packit.BuildContext, pnpmVersion string, logger scribe.Emitter) (packit.Layer, error) {
+	version, err := semver.NewVersion(pnpmVersion)
+	if err != nil {
+		return packit.Layer{}, fmt.Errorf("failed to parse pnpm version %q: %w", pnpmVersion, err)
+	}
+
+	major := int64(version.Major())
+	storeVersion := "v3"
+	if major >= 10 {
+		storeVersion = "v10"
+	}
+
+	logger.Process("Configuring pnpm store")
+
+	storeLayer, err := context.Layers.Get(PnpmStoreLayerName)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	cachedStoreVersion, ok := storeLayer.Metadata[StoreVersionKey].(string)
+	cachedMajor, _ := storeLayer.Metadata[PnpmMajorKey].(int64)
+	compatible := ok && cachedStoreVersion == storeVersion && cachedMajor == major
+
+	switch {
+	case compatible:
+		logger.Subprocess("Reusing pnpm store %s", storeLayer.Path)
+	case ok:
+		logger.Subprocess("Resetting pnpm store %s written by pnpm %d (store %s)", storeLayer.Path, cachedMajor, cachedStoreVersion)
+	default:
+		logger.Subprocess("Creating pnpm store %s", storeLayer.Path)
+	}
+	logger.Break()
+
+	if !compatible {
+		storeLayer, err = storeLayer.Reset()
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	storeLayer.Launch, storeLayer.Build, storeLayer.Cache = false, true, true
+
+	cachedMetadata := storeLayer.Metadata
+
+	storeLayer.Metadata = map[string]interface{}{
+		StoreVersionKey: storeVersion,
+		PnpmMajorKey:    major,
+	}
+
+	// What is known about the contents of the store stays valid as long as the
+	// store is.
+	if compatible {
+		for _, key := range []string{LockfileCacheKey, StoreSizeKey, BuildsSincePruneKey} {
+			if value, ok := cachedMetadata[key]; ok {
+				storeLayer.Metadata[key] = value
+			}
+		}
+	}
+
+	storeLayer.BuildEnv.Override("npm_config_store_dir", storeLayer.Path)
+	storeLayer.BuildEnv.Override("PNPM_STORE_DIR", storeLayer.Path)
+
+	return storeLayer, nil
+}
+
+// pruneStore logs the size of the pnpm store and runs pnpm store prune when
+// the store exceeds BP_PNPM_STORE_MAX_SIZE, or when it was last pruned more
+// than BP_PNPM_STORE_PRUNE_INTERVAL builds ago. The size of the store and the
+// number of builds since it was last pruned are recorded in the layer
+// metadata. The given environment is the one pnpm runs with.
+func pruneStore(
+	pnpmExecutable Executable,
+	storeLayer packit.Layer,
+	env []string,
+	clock chronos.Clock,
+	logger scribe.Emitter,
+) (packit.Layer, error) {
+	maxSize, err := checkStoreMaxSize()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	interval, err := checkStorePruneInterval()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	size, err := directorySize(storeLayer.Path)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Process("pnpm store size: %s", formatSize(size))
+
+	// A new store counts as pruned when it is created.
+	builds, _ := storeLayer.Metadata[BuildsSincePruneKey].(int64)
+
+	var reason string
+	switch {
+	case maxSize > 0 && size > maxSize:
+		reason = fmt.Sprintf("it exceeds BP_PNPM_STORE_MAX_SIZE of %s", formatSize(maxSize))
+	case interval > 0 && builds+1 > interval:
+		reason = fmt.Sprintf("it was last pruned %d builds ago", builds+1)
+	}
+
+	if reason == "" {
+		logger.Break()
+
+		storeLayer.Metadata[StoreSizeKey] = size
+		storeLayer.Metadata[BuildsSincePruneKey] = builds + 1
+
+		return storeLayer, nil
+	}
+
+	logger.Subprocess("Pruning the pnpm store because %s", reason)
+
+	buffer := bytes.NewBuffer(nil)
+	args := []string{"store", "prune"}
+	duration, err := clock.Measure(func() error {
+		return pnpmExecutable.Execute(pexec.Execution{
+			Args:   args,
+			Env:    env,
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+	})
+	if err != nil {
+		return packit.Layer{}, fmt.Errorf("failed to execute pnpm %s: %w\n%s", strings.Join(args, " "), err, buffer.String())
+	}
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+
+	size, err = directorySize(storeLayer.Path)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Subprocess("pnpm store size after pruning: %s", formatSize(size))
+	logger.Break()
+
+	storeLayer.Metadata[StoreSizeKey] = size
+	storeLayer.Metadata[BuildsSincePruneKey] = int64(0)
+
+	// Pruning may remove packages that were fetched but are not referenced by
+	// any project, so the store no longer holds everything the lockfile lists.
+	delete(storeLayer.Metadata, LockfileCacheKey)
+
+	return storeLayer, nil
+}
+
+// directorySize returns the total size of the regular files in the given
+// directory. Files linked several times into the directory count once for
+// every link.
+func directorySize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to measure the pnpm store: %w", err)
+	}
+
+	return size, nil
+}
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+// formatSize returns the given number of bytes in the largest binary unit in
+// which it is at least 1, e.g. 1.5 GiB.
+func formatSize(size int64) string {
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	return fmt.Sprintf("%.1f %s", value, sizeUnits[unit])
+}
+
+var sizeMultipliers = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?)(?:i?b)?$`)
+
+// checkStoreMaxSize returns the size in bytes given by
+// BP_PNPM_STORE_MAX_SIZE, e.g. 2G or 500MiB, or 0 when it is not set. Units
+// are binary multiples and default to bytes.
+func checkStoreMaxSize() (int64, error) {
+	sizeStr, ok := os.LookupEnv("BP_PNPM_STORE_MAX_SIZE")
+	if !ok || sizeStr == "" {
+		return 0, nil
+	}
+
+	matches := sizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(sizeStr)))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid BP_PNPM_STORE_MAX_SIZE value %q: must be a size such as 500M or 2G", sizeStr)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid BP_PNPM_STORE_MAX_SIZE value %q: %w", sizeStr, err)
+	}
+
+	return int64(value * sizeMultipliers[matches[2]]), nil
+}
+
+func checkStorePruneInterval() (int64, error) {
+	if intervalStr, ok := os.LookupEnv("BP_PNPM_STORE_PRUNE_INTERVAL"); ok {
+		interval, err := strconv.ParseInt(intervalStr, 10, 64)
+		if err != nil || interval < 0 {
+			return 0, fmt.Errorf("invalid BP_PNPM_STORE_PRUNE_INTERVAL value %q: must be a number of builds", intervalStr)
+		}
+		return interval, nil
+	}
+	return 0, nil
+}
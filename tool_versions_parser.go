@@ -0,0 +1,61 @@
+package pnpm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type ToolVersionsParser struct{}
+
+func NewToolVersionsParser() ToolVersionsParser {
+	return ToolVersionsParser{}
+}
+
+// ParseVersion returns the pnpm version pinned in the asdf .tool-versions file
+// at the given path. When several versions are listed, the first one is
+// returned as it is the one asdf would activate. It returns an empty string
+// when the file does not exist, does not mention pnpm, or pins it to a
+// non-release version such as "system" or "ref:<sha>".
+func (p ToolVersionsParser) ParseVersion(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to open .tool-versions: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != PnpmDependency {
+			continue
+		}
+
+		return releaseVersion(fields[1]), nil
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse .tool-versions: %w", err)
+	}
+
+	return "", nil
+}
+
+// releaseVersion filters out the version pins of asdf and mise that do not
+// refer to a published release of the tool.
+func releaseVersion(version string) string {
+	if version == "system" || strings.Contains(version, ":") {
+		return ""
+	}
+
+	return version
+}
@@ -0,0 +1,88 @@
+package pnpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pnpm"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testToolVersionsParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser pnpm.ToolVersionsParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), ".tool-versions")
+
+		parser = pnpm.NewToolVersionsParser()
+	})
+
+	context("ParseVersion", func() {
+		it("returns the first pinned pnpm version", func() {
+			Expect(os.WriteFile(path, []byte(`# toolchain
+nodejs 20.11.0
+pnpm   9.12.3 8.15.4 # fallback
+`), 0600)).To(Succeed())
+
+			version, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("9.12.3"))
+		})
+
+		context("when pnpm is not pinned", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte("nodejs 20.11.0\n# pnpm 9.12.3\n"), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when pnpm is pinned to a non-release version", func() {
+			it("returns an empty string", func() {
+				Expect(os.WriteFile(path, []byte("pnpm system\n"), 0600)).To(Succeed())
+
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+
+				Expect(os.WriteFile(path, []byte("pnpm ref:v9.12.3\n"), 0600)).To(Succeed())
+
+				version, err = parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when the .tool-versions file does not exist", func() {
+			it("returns an empty string", func() {
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the .tool-versions file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, nil, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to open .tool-versions")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package pnpm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// translateVersionRange converts an npm-style semver range, as found in the
+// engines and devEngines fields of a package.json, into a version constraint
+// that can be used to resolve a dependency from the buildpack.toml.
+func translateVersionRange(versionRange string) (string, error) {
+	versionRange = strings.Join(strings.Fields(versionRange), " ")
+
+	switch versionRange {
+	case "", "*", "x", "X", "latest":
+		return "*", nil
+	}
+
+	_, err := semver.NewConstraint(versionRange)
+	if err != nil {
+		return "", fmt.Errorf("invalid pnpm version range %q: %w", versionRange, err)
+	}
+
+	return versionRange, nil
+}
@@ -0,0 +1,75 @@
+package pnpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// findProjectPath returns the directory of the application to build, given
+// by BP_NODE_PROJECT_PATH relative to the working directory.
+func findProjectPath(workingDir string) (string, error) {
+	projectPath := filepath.Join(workingDir, os.Getenv("BP_NODE_PROJECT_PATH"))
+
+	if !isWithin(workingDir, projectPath) {
+		return "", fmt.Errorf("BP_NODE_PROJECT_PATH %q points outside of the working directory", os.Getenv("BP_NODE_PROJECT_PATH"))
+	}
+
+	exists, err := fs.Exists(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		return "", fmt.Errorf("could not find project path %q: %w", projectPath, os.ErrNotExist)
+	}
+
+	return projectPath, nil
+}
+
+// findWorkspaceRoot walks up from the project path to the working directory
+// and returns the closest directory containing a pnpm-workspace.yaml file. It
+// returns the project path when the project is not part of a workspace.
+func findWorkspaceRoot(projectPath, workingDir string) (string, error) {
+	dir := projectPath
+	for {
+		exists, err := fs.Exists(filepath.Join(dir, "pnpm-workspace.yaml"))
+		if err != nil {
+			return "", err
+		}
+
+		if exists {
+			return dir, nil
+		}
+
+		if dir == workingDir || dir == filepath.Dir(dir) {
+			return projectPath, nil
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+// searchDirs returns the directories in which version pins are looked up:
+// the project path, followed by the workspace root when it differs.
+func searchDirs(projectPath, workspaceRoot string) []string {
+	if projectPath == workspaceRoot {
+		return []string{projectPath}
+	}
+
+	return []string{projectPath, workspaceRoot}
+}
+
+// isWithin reports whether the given path is located inside of the root
+// directory.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}